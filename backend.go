@@ -0,0 +1,242 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Backend is an attribute I/O backend. Paths passed to a Backend are
+// absolute paths including any prefix set by SetPrefix.
+//
+// Backend implementations should return errors satisfying os.IsNotExist
+// for paths that do not exist.
+type Backend interface {
+	// Read returns the contents of the
+	// attribute file at path.
+	Read(path string) ([]byte, error)
+
+	// Write writes data to the attribute
+	// file at path.
+	Write(path string, data []byte) error
+
+	// List returns the names of the
+	// entries in the directory at path.
+	List(path string) ([]string, error)
+}
+
+// backend is the Backend used for all attribute I/O.
+var backend Backend = SysfsBackend{}
+
+// SetBackend sets the Backend used for all device attribute I/O and returns
+// the previously used Backend. If b is nil, SysfsBackend is used. SetBackend
+// must not be called concurrently with device access.
+func SetBackend(b Backend) (prev Backend) {
+	prev = backend
+	if b == nil {
+		b = SysfsBackend{}
+	}
+	backend = b
+	return prev
+}
+
+// CurrentBackend returns the Backend used for device attribute I/O.
+func CurrentBackend() Backend {
+	return backend
+}
+
+// IsFileBackend returns whether b accesses attribute files directly through
+// the file system, allowing the use of poll(2) and persistent file handles.
+// SysfsBackend and CachedBackend are file backends.
+func IsFileBackend(b Backend) bool {
+	switch b.(type) {
+	case SysfsBackend, *SysfsBackend, *CachedBackend:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSysfs returns whether the current Backend is a file backend.
+func isSysfs() bool {
+	return IsFileBackend(backend)
+}
+
+// SysfsBackend is a Backend using the file system. It is the default Backend.
+type SysfsBackend struct{}
+
+// Read returns the contents of the file at path.
+func (SysfsBackend) Read(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+// Write writes data to the existing file at path.
+func (SysfsBackend) Write(path string, data []byte) error {
+	return ioutil.WriteFile(path, data, 0)
+}
+
+// List returns the names of the entries in the directory at path.
+func (SysfsBackend) List(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(0)
+}
+
+// MemoryBackend is a Backend that holds attribute files in memory. Writes
+// create files if they do not exist and directories are implied by the
+// paths of the files they contain. It is safe for concurrent use.
+type MemoryBackend struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemoryBackend returns a new empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{files: make(map[string][]byte)}
+}
+
+// Read returns the contents of the file at path.
+func (b *MemoryBackend) Read(path string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.files[filepath.Clean(path)]
+	if !ok {
+		if b.isDir(path) {
+			return nil, &os.PathError{Op: "read", Path: path, Err: syscall.EISDIR}
+		}
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Write writes data to the file at path, creating it if necessary.
+func (b *MemoryBackend) Write(path string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isDir(path) {
+		return &os.PathError{Op: "open", Path: path, Err: syscall.EISDIR}
+	}
+	b.files[filepath.Clean(path)] = append([]byte(nil), data...)
+	return nil
+}
+
+// List returns the sorted names of the entries in the directory at path.
+func (b *MemoryBackend) List(path string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	if _, ok := b.files[path]; ok {
+		return nil, &os.PathError{Op: "readdirent", Path: path, Err: syscall.ENOTDIR}
+	}
+	dir := path + string(filepath.Separator)
+	seen := make(map[string]bool)
+	var names []string
+	for p := range b.files {
+		if !strings.HasPrefix(p, dir) {
+			continue
+		}
+		n := p[len(dir):]
+		if i := strings.IndexRune(n, filepath.Separator); i >= 0 {
+			n = n[:i]
+		}
+		if !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	if names == nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove removes the file or directory tree at path.
+func (b *MemoryBackend) Remove(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	path = filepath.Clean(path)
+	dir := path + string(filepath.Separator)
+	for p := range b.files {
+		if p == path || strings.HasPrefix(p, dir) {
+			delete(b.files, p)
+		}
+	}
+}
+
+// isDir returns whether path is an implied directory. b.mu must be held.
+func (b *MemoryBackend) isDir(path string) bool {
+	dir := filepath.Clean(path) + string(filepath.Separator)
+	for p := range b.files {
+		if strings.HasPrefix(p, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoggingBackend is a Backend that logs all operations performed by another
+// Backend.
+type LoggingBackend struct {
+	// Backend is the Backend performing
+	// the logged operations.
+	Backend Backend
+
+	// Logger is the destination for log
+	// messages. If Logger is nil, the
+	// standard logger is used.
+	Logger *log.Logger
+}
+
+func (b LoggingBackend) logf(format string, args ...interface{}) {
+	if b.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	b.Logger.Printf(format, args...)
+}
+
+// Read reads the file at path using the underlying Backend.
+func (b LoggingBackend) Read(path string) ([]byte, error) {
+	data, err := b.Backend.Read(path)
+	if err != nil {
+		b.logf("ev3dev: read %s: %v", path, err)
+	} else {
+		b.logf("ev3dev: read %s: %q", path, data)
+	}
+	return data, err
+}
+
+// Write writes to the file at path using the underlying Backend.
+func (b LoggingBackend) Write(path string, data []byte) error {
+	err := b.Backend.Write(path, data)
+	if err != nil {
+		b.logf("ev3dev: write %s: %q: %v", path, data, err)
+	} else {
+		b.logf("ev3dev: write %s: %q", path, data)
+	}
+	return err
+}
+
+// List lists the directory at path using the underlying Backend.
+func (b LoggingBackend) List(path string) ([]string, error) {
+	names, err := b.Backend.List(path)
+	if err != nil {
+		b.logf("ev3dev: list %s: %v", path, err)
+	} else {
+		b.logf("ev3dev: list %s: %q", path, names)
+	}
+	return names, err
+}
@@ -0,0 +1,297 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	b := NewMemoryBackend()
+
+	_, err := b.Read("/sys/class/leds/led0/brightness")
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error reading missing file: got:%v want not exist error", err)
+	}
+	_, err = b.List("/sys/class/leds")
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error listing missing directory: got:%v want not exist error", err)
+	}
+
+	for _, f := range []struct{ path, data string }{
+		{"/sys/class/leds/led1/brightness", "255\n"},
+		{"/sys/class/leds/led0/brightness", "0\n"},
+		{"/sys/class/leds/led0/trigger", "[none] timer\n"},
+	} {
+		err = b.Write(f.path, []byte(f.data))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", f.path, err)
+		}
+	}
+
+	got, err := b.Read("/sys/class/leds/led0/brightness")
+	if err != nil || string(got) != "0\n" {
+		t.Errorf("unexpected read result: got:%q want:%q (err=%v)", got, "0\n", err)
+	}
+	got[0] = 'x'
+	got, _ = b.Read("/sys/class/leds/led0/brightness")
+	if string(got) != "0\n" {
+		t.Errorf("read data aliases backend storage: got:%q", got)
+	}
+
+	names, err := b.List("/sys/class/leds")
+	if err != nil || !reflect.DeepEqual(names, []string{"led0", "led1"}) {
+		t.Errorf("unexpected directory list: got:%q want:%q (err=%v)", names, []string{"led0", "led1"}, err)
+	}
+	names, err = b.List("/sys/class/leds/led0/")
+	if err != nil || !reflect.DeepEqual(names, []string{"brightness", "trigger"}) {
+		t.Errorf("unexpected directory list: got:%q want:%q (err=%v)", names, []string{"brightness", "trigger"}, err)
+	}
+	_, err = b.List("/sys/class/leds/led0/brightness")
+	if err == nil {
+		t.Error("expected error listing file")
+	}
+	_, err = b.Read("/sys/class/leds/led0")
+	if err == nil || os.IsNotExist(err) {
+		t.Errorf("unexpected error reading directory: got:%v", err)
+	}
+	err = b.Write("/sys/class/leds/led0", nil)
+	if err == nil {
+		t.Error("expected error writing directory")
+	}
+
+	b.Remove("/sys/class/leds/led0")
+	names, err = b.List("/sys/class/leds")
+	if err != nil || !reflect.DeepEqual(names, []string{"led1"}) {
+		t.Errorf("unexpected directory list after remove: got:%q want:%q (err=%v)", names, []string{"led1"}, err)
+	}
+}
+
+func TestLoggingBackend(t *testing.T) {
+	mem := NewMemoryBackend()
+	var buf bytes.Buffer
+	b := LoggingBackend{Backend: mem, Logger: log.New(&buf, "", 0)}
+
+	err := b.Write("/sys/class/leds/led0/brightness", []byte("1"))
+	if err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	got, err := b.Read("/sys/class/leds/led0/brightness")
+	if err != nil || string(got) != "1" {
+		t.Errorf("unexpected read result: got:%q want:%q (err=%v)", got, "1", err)
+	}
+	_, err = b.Read("/sys/class/leds/led0/trigger")
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error reading missing file: got:%v want not exist error", err)
+	}
+	_, err = b.List("/sys/class/leds")
+	if err != nil {
+		t.Errorf("unexpected error listing: %v", err)
+	}
+
+	want := []string{
+		`ev3dev: write /sys/class/leds/led0/brightness: "1"`,
+		`ev3dev: read /sys/class/leds/led0/brightness: "1"`,
+		`ev3dev: read /sys/class/leds/led0/trigger: open /sys/class/leds/led0/trigger: file does not exist`,
+		`ev3dev: list /sys/class/leds: ["led0"]`,
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("unexpected log:\ngot: %q\nwant:%q", lines, want)
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+	if CurrentBackend() != Backend(mem) {
+		t.Fatal("backend not set")
+	}
+
+	dir := filepath.Join(prefix, TachoMotorPath, "motor3")
+	for attr, val := range map[string]string{
+		address:       "outC",
+		driverName:    "lego-ev3-l-motor",
+		commands:      "run-forever stop reset",
+		speedSetpoint: "0",
+		state:         "running stalled",
+	} {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+
+	m, err := TachoMotorFor("outC", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+	if m.String() != "motor3" {
+		t.Errorf("unexpected motor: got:%s want:motor3", m)
+	}
+	ok, err := IsConnected(m)
+	if err != nil || !ok {
+		t.Errorf("unexpected connection status: got:%t want:true (err=%v)", ok, err)
+	}
+	err = m.SetSpeedSetpoint(300).Command("run-forever").Err()
+	if err != nil {
+		t.Fatalf("unexpected error commanding motor: %v", err)
+	}
+	got, err := mem.Read(filepath.Join(dir, command))
+	if err != nil || string(got) != "run-forever" {
+		t.Errorf("unexpected command attribute: got:%q want:%q (err=%v)", got, "run-forever", err)
+	}
+	sp, err := m.SpeedSetpoint()
+	if err != nil || sp != 300 {
+		t.Errorf("unexpected speed setpoint: got:%d want:300 (err=%v)", sp, err)
+	}
+
+	stat, ok, err := Wait(m, Stalled, Stalled, 0, false, 10*time.Millisecond)
+	if err != nil || !ok || stat != Running|Stalled {
+		t.Errorf("unexpected wait result: stat=%v ok=%t err=%v", stat, ok, err)
+	}
+
+	mem.Remove(dir)
+	ok, err = IsConnected(m)
+	if err != nil || ok {
+		t.Errorf("unexpected connection status after removal: got:%t want:false (err=%v)", ok, err)
+	}
+	err = mem.Write(filepath.Join(prefix, TachoMotorPath, "motor4", address), []byte("outD\n"))
+	if err != nil {
+		t.Fatalf("unexpected error writing address: %v", err)
+	}
+	ok, err = IsConnected(m)
+	if err != nil || ok {
+		t.Errorf("unexpected connection status with other motor present: got:%t want:false (err=%v)", ok, err)
+	}
+
+	SetBackend(nil)
+	if _, ok := CurrentBackend().(SysfsBackend); !ok {
+		t.Errorf("unexpected backend after reset: got:%T want:SysfsBackend", CurrentBackend())
+	}
+}
+
+func TestIsFileBackend(t *testing.T) {
+	for _, test := range []struct {
+		b    Backend
+		want bool
+	}{
+		{b: SysfsBackend{}, want: true},
+		{b: &SysfsBackend{}, want: true},
+		{b: NewCachedBackend(), want: true},
+		{b: NewMemoryBackend(), want: false},
+		{b: LoggingBackend{Backend: SysfsBackend{}}, want: false},
+	} {
+		if got := IsFileBackend(test.b); got != test.want {
+			t.Errorf("unexpected result for %T: got:%t want:%t", test.b, got, test.want)
+		}
+	}
+}
+
+func TestWaitContextBackend(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+
+	dir := filepath.Join(prefix, TachoMotorPath, "motor0")
+	for attr, val := range map[string]string{
+		address:    "outA",
+		driverName: "lego-ev3-l-motor",
+		state:      "running",
+	} {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+	m, err := TachoMotorFor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+
+	for _, timeout := range []time.Duration{time.Millisecond, 3 * time.Millisecond, 20 * time.Millisecond} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		stat, ok, err := WaitContext(ctx, m, Stalled, Stalled, 0, false)
+		cancel()
+		if ok || err != context.DeadlineExceeded || stat != Running {
+			t.Errorf("unexpected wait result for timeout %v: stat=%v ok=%t err=%v", timeout, stat, ok, err)
+		}
+	}
+}
+
+// readHook is a Backend that calls hook before each read.
+type readHook struct {
+	Backend
+	hook func(path string)
+}
+
+func (b readHook) Read(path string) ([]byte, error) {
+	b.hook(path)
+	return b.Backend.Read(path)
+}
+
+func TestWaitKeepsError(t *testing.T) {
+	mem := NewMemoryBackend()
+	reads := make(chan struct{}, 100)
+	prev := SetBackend(readHook{Backend: mem, hook: func(path string) {
+		if filepath.Base(path) == state {
+			reads <- struct{}{}
+		}
+	}})
+	defer SetBackend(prev)
+
+	dir := filepath.Join(prefix, TachoMotorPath, "motor0")
+	for attr, val := range map[string]string{
+		address:    "outA",
+		driverName: "lego-ev3-l-motor",
+		commands:   "run-forever stop",
+		state:      "running",
+	} {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+	m, err := TachoMotorFor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WaitContext(ctx, m, Stalled, Stalled, 0, false)
+	}()
+	waitRead := func() {
+		select {
+		case <-reads:
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for state read")
+		}
+	}
+	waitRead()
+
+	m.Command("invalid")
+	for i := 0; i < 2; i++ {
+		waitRead()
+	}
+	cancel()
+	<-done
+	if m.Err() == nil {
+		t.Error("expected error state to be kept during wait")
+	}
+}
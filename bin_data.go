@@ -0,0 +1,144 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Sensor bin_data_format values.
+const (
+	binU8    = "u8"
+	binS8    = "s8"
+	binU16   = "u16"
+	binS16   = "s16"
+	binS16BE = "s16_be"
+	binS32   = "s32"
+	binS32BE = "s32_be"
+	binFloat = "float"
+)
+
+// BinDataSize returns the size in bytes of a single value in the given
+// bin_data_format. BinDataSize returns -1 if the format is not recognized.
+func BinDataSize(format string) int {
+	switch format {
+	case binU8, binS8:
+		return 1
+	case binU16, binS16, binS16BE:
+		return 2
+	case binS32, binS32BE, binFloat:
+		return 4
+	default:
+		return -1
+	}
+}
+
+// DecodeBinData decodes n values from the raw bytes b according to the
+// given bin_data_format, as returned by Sensor.BinDataFormat. If n is
+// negative, all complete values held in b are decoded.
+//
+// The concrete type of the returned value depends on format:
+//
+//	u8: []uint8
+//	s8: []int8
+//	u16: []uint16
+//	s16: []int16
+//	s16_be: []int16
+//	s32: []int32
+//	s32_be: []int32
+//	float: []float32
+//
+// Multi-byte values are little endian unless the format specifies big
+// endian.
+func DecodeBinData(format string, b []byte, n int) (interface{}, error) {
+	sz := BinDataSize(format)
+	if sz < 0 {
+		return nil, fmt.Errorf("ev3dev: unrecognized bin data format %q", format)
+	}
+	if n < 0 {
+		n = len(b) / sz
+	}
+	if len(b) < n*sz {
+		return nil, fmt.Errorf("ev3dev: short bin data for %d %s values: have %d bytes want %d", n, format, len(b), n*sz)
+	}
+
+	switch format {
+	case binU8:
+		v := make([]uint8, n)
+		copy(v, b)
+		return v, nil
+	case binS8:
+		v := make([]int8, n)
+		for i := range v {
+			v[i] = int8(b[i])
+		}
+		return v, nil
+	case binU16:
+		v := make([]uint16, n)
+		for i := range v {
+			v[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+		return v, nil
+	case binS16:
+		v := make([]int16, n)
+		for i := range v {
+			v[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+		}
+		return v, nil
+	case binS16BE:
+		v := make([]int16, n)
+		for i := range v {
+			v[i] = int16(binary.BigEndian.Uint16(b[2*i:]))
+		}
+		return v, nil
+	case binS32:
+		v := make([]int32, n)
+		for i := range v {
+			v[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+		return v, nil
+	case binS32BE:
+		v := make([]int32, n)
+		for i := range v {
+			v[i] = int32(binary.BigEndian.Uint32(b[4*i:]))
+		}
+		return v, nil
+	case binFloat:
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+		return v, nil
+	default:
+		panic("ev3dev: unreachable")
+	}
+}
+
+// DecodedBinData returns the unscaled raw values from the Sensor decoded
+// according to the bin_data_format of the current mode. The concrete type
+// of the returned value is described in the documentation for DecodeBinData.
+//
+// The number of values and bin_data_format are cached in the same way as
+// for ScaledValues.
+func (s *Sensor) DecodedBinData() (interface{}, error) {
+	err := s.Err()
+	if err != nil {
+		return nil, err
+	}
+	if s.meta == nil {
+		meta, err := s.modeMeta()
+		if err != nil {
+			return nil, err
+		}
+		s.meta = meta
+	}
+	b, err := s.BinData()
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBinData(s.meta.binDataFormat, b, s.meta.numValues)
+}
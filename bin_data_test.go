@@ -0,0 +1,55 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	. "github.com/ev3go/ev3dev"
+)
+
+var decodeBinDataTests = []struct {
+	format string
+	data   []byte
+	n      int
+
+	want    interface{}
+	wantErr bool
+}{
+	{format: "u8", data: []byte{0x01, 0xff, 0x80}, n: 3, want: []uint8{1, 255, 128}},
+	{format: "u8", data: []byte{0x01, 0xff, 0x80}, n: 2, want: []uint8{1, 255}},
+	{format: "s8", data: []byte{0x01, 0xff, 0x80}, n: 3, want: []int8{1, -1, -128}},
+	{format: "u16", data: []byte{0x01, 0x00, 0xff, 0xff}, n: 2, want: []uint16{1, 0xffff}},
+	{format: "s16", data: []byte{0x01, 0x00, 0xff, 0xff}, n: 2, want: []int16{1, -1}},
+	{format: "s16", data: []byte{0x01, 0x00, 0x02, 0x00, 0x03, 0x00}, n: -1, want: []int16{1, 2, 3}},
+	{format: "s16_be", data: []byte{0x00, 0x01, 0x80, 0x00}, n: 2, want: []int16{1, math.MinInt16}},
+	{format: "s32", data: []byte{0x01, 0x00, 0x00, 0x00, 0xfe, 0xff, 0xff, 0xff}, n: 2, want: []int32{1, -2}},
+	{format: "s32_be", data: []byte{0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xfe}, n: 2, want: []int32{1, -2}},
+	{format: "float", data: []byte{0x00, 0x00, 0x80, 0x3f, 0x00, 0x00, 0x20, 0xc1}, n: 2, want: []float32{1, -10}},
+
+	{format: "s16", data: []byte{0x01, 0x00, 0x02}, n: 2, wantErr: true},
+	{format: "u64", data: []byte{0x01, 0x00}, n: 1, wantErr: true},
+}
+
+func TestDecodeBinData(t *testing.T) {
+	for _, test := range decodeBinDataTests {
+		got, err := DecodeBinData(test.format, test.data, test.n)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("expected error for format %q with data %#x", test.format, test.data)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for format %q: %v", test.format, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected decoded value for format %q: got:%#v want:%#v", test.format, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ButtonTracker keeps track of the debounced state of the buttons and how
+// long they have been held, using button events such as the Events of a
+// ButtonWaiter.
+type ButtonTracker struct {
+	mu      sync.Mutex
+	state   debouncer
+	changed chan struct{}
+	err     error
+
+	// start and offset map the time
+	// since start into the time base
+	// of the event time stamps.
+	start  time.Time
+	offset time.Duration
+
+	done    chan struct{}
+	once    sync.Once
+	stopped chan struct{}
+}
+
+// NewButtonTracker returns a ButtonTracker reading button events from
+// events. A change in the state of a button is accepted when it has been
+// stable for the debounce duration. If debounce is zero, changes are
+// accepted immediately. The ButtonTracker stops when events is closed,
+// when an event with a non-nil Err is received or when it is closed.
+func NewButtonTracker(events <-chan ButtonEvent, debounce time.Duration) (*ButtonTracker, error) {
+	if debounce < 0 {
+		return nil, fmt.Errorf("ev3dev: invalid debounce duration: %v", debounce)
+	}
+	t := &ButtonTracker{
+		state:   debouncer{debounce: debounce},
+		changed: make(chan struct{}),
+		start:   time.Now(),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.run(events)
+	return t, nil
+}
+
+func (t *ButtonTracker) run(events <-chan ButtonEvent) {
+	defer close(t.stopped)
+
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		var wake <-chan time.Time
+		t.mu.Lock()
+		if d, ok := t.state.next(); ok {
+			timer.Reset(d - t.now())
+			wake = timer.C
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-t.done:
+			return
+		case e, ok := <-events:
+			if !ok {
+				t.stop(nil)
+				return
+			}
+			if e.Err != nil {
+				t.stop(e.Err)
+				return
+			}
+			if e.Type != ev_key || e.Button == 0 || (e.Value != keyPress && e.Value != keyRelease) {
+				break
+			}
+			t.mu.Lock()
+			t.offset = e.TimeStamp - time.Since(t.start)
+			t.state.event(e.Button, e.Value == keyPress, e.TimeStamp)
+			if t.state.commit(e.TimeStamp) {
+				t.notify()
+			}
+			t.mu.Unlock()
+		case <-wake:
+			wake = nil
+			t.mu.Lock()
+			if t.state.commit(t.now()) {
+				t.notify()
+			}
+			t.mu.Unlock()
+		}
+		if wake != nil && !timer.Stop() {
+			<-timer.C
+		}
+	}
+}
+
+// now returns the current time in the event time base. t.mu must be held.
+func (t *ButtonTracker) now() time.Duration {
+	return time.Since(t.start) + t.offset
+}
+
+// notify wakes all waiters. t.mu must be held.
+func (t *ButtonTracker) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// stop records the error ending the event stream and wakes all waiters.
+func (t *ButtonTracker) stop(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		err = errors.New("ev3dev: button events ended")
+	}
+	t.err = err
+	t.notify()
+}
+
+// State returns the set of buttons currently pressed.
+func (t *ButtonTracker) State() Button {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.pressed
+}
+
+// Pressed returns whether all the buttons in b are currently pressed.
+func (t *ButtonTracker) Pressed(b Button) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return b != 0 && t.state.pressed&b == b
+}
+
+// HeldFor returns how long all the buttons in b have been held together.
+// If any button in b is not pressed, HeldFor returns zero.
+func (t *ButtonTracker) HeldFor(b Button) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if b == 0 || t.state.pressed&b != b {
+		return 0
+	}
+	var latest time.Duration
+	for i := range t.state.buttons {
+		if b&(1<<uint(i)) != 0 && t.state.buttons[i].since > latest {
+			latest = t.state.buttons[i].since
+		}
+	}
+	held := t.now() - latest
+	if held < 0 {
+		return 0
+	}
+	return held
+}
+
+// WaitFor blocks until all the buttons in mask are pressed or the timeout
+// is reached. If timeout is negative, WaitFor waits indefinitely. The
+// current button state is returned with ok true if all the buttons in
+// mask are pressed. WaitFor returns an error if the ButtonTracker has
+// stopped before the buttons are pressed.
+func (t *ButtonTracker) WaitFor(mask Button, timeout time.Duration) (stat Button, ok bool, err error) {
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		t.mu.Lock()
+		stat = t.state.pressed
+		changed := t.changed
+		err = t.err
+		t.mu.Unlock()
+		if stat&mask == mask {
+			return stat, true, nil
+		}
+		if err != nil {
+			return stat, false, err
+		}
+		select {
+		case <-changed:
+		case <-expired:
+			return stat, false, nil
+		}
+	}
+}
+
+// Err returns the error that stopped the ButtonTracker, if any.
+func (t *ButtonTracker) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close stops the ButtonTracker. It does not close the source of button
+// events. Close is safe to call more than once and concurrently.
+func (t *ButtonTracker) Close() error {
+	t.once.Do(func() { close(t.done) })
+	<-t.stopped
+	t.mu.Lock()
+	if t.err == nil {
+		t.err = errors.New("ev3dev: button tracker closed")
+		t.notify()
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// debouncer holds the raw and debounced button states. All times are in
+// the time base of the button event time stamps.
+type debouncer struct {
+	debounce time.Duration
+
+	// pressed is the debounced
+	// button state.
+	pressed Button
+
+	buttons [len(buttons)]struct {
+		// raw is the most recently seen
+		// state of the button and rawAt
+		// is when it was first seen.
+		raw   bool
+		rawAt time.Duration
+
+		// since is when the debounced
+		// state of the button last
+		// changed.
+		since time.Duration
+	}
+}
+
+// event records a raw state change of the buttons in b at time ts.
+func (d *debouncer) event(b Button, pressed bool, ts time.Duration) {
+	for i := range d.buttons {
+		if b&(1<<uint(i)) == 0 {
+			continue
+		}
+		s := &d.buttons[i]
+		if s.raw != pressed {
+			s.raw = pressed
+			s.rawAt = ts
+		}
+	}
+}
+
+// commit accepts raw states that have been stable for the debounce
+// duration at time now, returning whether the debounced state changed.
+func (d *debouncer) commit(now time.Duration) bool {
+	changed := false
+	for i := range d.buttons {
+		s := &d.buttons[i]
+		bit := Button(1 << uint(i))
+		if s.raw == (d.pressed&bit != 0) || s.rawAt+d.debounce > now {
+			continue
+		}
+		d.pressed ^= bit
+		s.since = s.rawAt
+		changed = true
+	}
+	return changed
+}
+
+// next returns the time of the next pending commit, and whether
+// there is one.
+func (d *debouncer) next() (time.Duration, bool) {
+	var (
+		next time.Duration
+		ok   bool
+	)
+	for i, s := range d.buttons {
+		if s.raw == (d.pressed&(1<<uint(i)) != 0) {
+			continue
+		}
+		at := s.rawAt + d.debounce
+		if !ok || at < next {
+			next = at
+			ok = true
+		}
+	}
+	return next, ok
+}
@@ -0,0 +1,119 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	d := debouncer{debounce: 10 * ms}
+
+	// Bouncing press.
+	d.event(Left, true, 100*ms)
+	d.event(Left, false, 102*ms)
+	d.event(Left, true, 104*ms)
+	if d.commit(110 * ms) {
+		t.Error("unexpected commit of unstable press")
+	}
+	next, ok := d.next()
+	if !ok || next != 114*ms {
+		t.Errorf("unexpected next commit time: got:%v want:%v (ok=%t)", next, 114*ms, ok)
+	}
+	if !d.commit(114*ms) || d.pressed != Left || d.buttons[1].since != 104*ms {
+		t.Errorf("unexpected state after stable press: pressed=%v since=%v", d.pressed, d.buttons[1].since)
+	}
+	if _, ok := d.next(); ok {
+		t.Error("unexpected pending commit")
+	}
+
+	// Glitch while held.
+	d.event(Left, false, 200*ms)
+	d.event(Left, true, 203*ms)
+	d.commit(300 * ms)
+	if d.pressed != Left {
+		t.Errorf("unexpected state after glitch: got:%v want:%v", d.pressed, Left)
+	}
+
+	d.event(Left, false, 400*ms)
+	d.event(Right|Up, true, 401*ms)
+	if !d.commit(411*ms) || d.pressed != Right|Up {
+		t.Errorf("unexpected state after release: got:%v want:%v", d.pressed, Right|Up)
+	}
+}
+
+func TestButtonTracker(t *testing.T) {
+	events := make(chan ButtonEvent)
+	tr, err := NewButtonTracker(events, 5*ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer tr.Close()
+
+	stat, ok, err := tr.WaitFor(Left, 10*ms)
+	if ok || err != nil || stat != 0 {
+		t.Errorf("unexpected wait result with no presses: stat=%v ok=%t err=%v", stat, ok, err)
+	}
+
+	base := 1000 * time.Second
+	events <- pressEvent(Left, base)
+	events <- pressEvent(Down, base)
+	stat, ok, err = tr.WaitFor(Left|Down, time.Second)
+	if !ok || err != nil || stat != Left|Down {
+		t.Errorf("unexpected wait result: stat=%v ok=%t err=%v", stat, ok, err)
+	}
+	if !tr.Pressed(Left) || !tr.Pressed(Left|Down) || tr.Pressed(Left|Up) {
+		t.Errorf("unexpected pressed state: %v", tr.State())
+	}
+	time.Sleep(20 * ms)
+	held := tr.HeldFor(Left)
+	if held < 20*ms || held > time.Second {
+		t.Errorf("unexpected held duration: got:%v", held)
+	}
+	if d := tr.HeldFor(Up); d != 0 {
+		t.Errorf("unexpected held duration for released button: got:%v", d)
+	}
+
+	events <- releaseEvent(Left, base+30*ms)
+	time.Sleep(20 * ms)
+	if tr.Pressed(Left) || !tr.Pressed(Down) {
+		t.Errorf("unexpected pressed state after release: %v", tr.State())
+	}
+	readErr := errors.New("read error")
+	events <- ButtonEvent{Err: readErr}
+	stat, ok, err = tr.WaitFor(Left, -1)
+	if ok || err != readErr {
+		t.Errorf("unexpected wait result after error: stat=%v ok=%t err=%v", stat, ok, err)
+	}
+}
+
+func TestButtonTrackerClose(t *testing.T) {
+	tr, err := NewButtonTracker(make(chan ButtonEvent), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Concurrent calls to Close must not panic.
+	closed := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() { closed <- tr.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-closed:
+			if err != nil {
+				t.Errorf("unexpected error closing button tracker: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for close")
+		}
+	}
+	_, ok, err := tr.WaitFor(Left, -1)
+	if ok || err == nil {
+		t.Errorf("unexpected wait result after close: ok=%t err=%v", ok, err)
+	}
+}
@@ -5,7 +5,9 @@
 package ev3dev
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,12 +15,34 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // ButtonPoller allows polling of the ev3 buttons. The zero
 // value is ready for use.
 type ButtonPoller struct {
-	buf []byte
+	// Device is the path of an input
+	// event device to poll, decoded
+	// with EV3KeyMap.
+	Device string
+
+	// Inputs holds additional input
+	// event devices to poll. The
+	// button states of Device and all
+	// the Inputs are merged.
+	//
+	// If Device and Inputs are both
+	// empty, the device is found using
+	// ButtonDevice on the first call
+	// to Poll, and is decoded with the
+	// KeyMap of its InputDevice's
+	// ButtonInput.
+	Inputs []ButtonInput
+
+	input *ButtonInput
+	buf   []byte
 }
 
 // Poll returns a set of Button flags indicating which buttons
@@ -27,7 +51,33 @@ func (b *ButtonPoller) Poll() (Button, error) {
 	if b.buf == nil {
 		b.buf = make([]byte, keyBufLen)
 	}
-	ev, err := os.Open(ButtonPath)
+	inputs := b.Inputs
+	if b.Device != "" {
+		inputs = append([]ButtonInput{{Path: b.Device}}, inputs...)
+	}
+	if len(inputs) == 0 {
+		if b.input == nil {
+			in, err := buttonInputIn(InputPath, probeInput)
+			if err != nil {
+				return 0, err
+			}
+			b.input = &in
+		}
+		inputs = []ButtonInput{*b.input}
+	}
+	var pressed Button
+	for _, in := range inputs {
+		p, err := b.poll(in)
+		if err != nil {
+			return 0, err
+		}
+		pressed |= p
+	}
+	return pressed, nil
+}
+
+func (b *ButtonPoller) poll(in ButtonInput) (Button, error) {
+	ev, err := os.Open(in.Path)
 	if err != nil {
 		return 0, fmt.Errorf("ev3dev: failed to open button event device: %v", err)
 	}
@@ -37,87 +87,326 @@ func (b *ButtonPoller) Poll() (Button, error) {
 	if err != nil {
 		return 0, fmt.Errorf("ev3dev: failed to set ioctl command for button event device: %v", err)
 	}
-	return getButton(b.buf), nil
+	return getButton(b.buf, in.keyMap()), nil
 }
 
-func getButton(buf []byte) Button {
+func getButton(buf []byte, m *KeyMap) Button {
 	var pressed Button
-	for i, bit := range &buttons {
-		if !isSet(bit, buf) {
-			pressed |= 1 << uint(i)
+	for code, b := range m.Keys {
+		if code < uint(len(buf))*8 && isSet(code, buf) != m.Inverted {
+			pressed |= b
 		}
 	}
 	return pressed
 }
 
+// ButtonInput is an input event device used as a source of button
+// states and events.
+type ButtonInput struct {
+	// Path is the path of the input
+	// event device.
+	Path string
+
+	// Keys is the KeyMap used to
+	// decode the key codes of the
+	// device. If Keys is nil,
+	// EV3KeyMap is used.
+	Keys *KeyMap
+}
+
+func (in ButtonInput) keyMap() *KeyMap {
+	if in.Keys == nil {
+		return &EV3KeyMap
+	}
+	return in.Keys
+}
+
+// KeyMap maps the linux key codes reported by an input event device
+// to Button values.
+type KeyMap struct {
+	// Keys maps key codes to the
+	// buttons they represent. Key
+	// codes that are not in Keys
+	// are ignored.
+	Keys map[uint]Button
+
+	// Inverted indicates that the
+	// device reports pressed keys as
+	// clear bits in its key state, as
+	// the EV3 button driver does.
+	Inverted bool
+}
+
+// EV3KeyMap is the KeyMap of the EV3 brick buttons.
+var EV3KeyMap = KeyMap{
+	Keys: map[uint]Button{
+		key_backspace: Back,
+		key_left:      Left,
+		key_enter:     Middle,
+		key_right:     Right,
+		key_up:        Up,
+		key_down:      Down,
+	},
+	Inverted: true,
+}
+
+// KeyboardKeyMap is a KeyMap for a standard keyboard. The arrow keys
+// map to the direction buttons, Enter, keypad Enter and Space map to
+// Middle, and Backspace and Escape map to Back.
+var KeyboardKeyMap = KeyMap{
+	Keys: map[uint]Button{
+		key_backspace: Back,
+		key_esc:       Back,
+		key_left:      Left,
+		key_enter:     Middle,
+		key_kpenter:   Middle,
+		key_space:     Middle,
+		key_right:     Right,
+		key_up:        Up,
+		key_down:      Down,
+	},
+}
+
 // ButtonWaiter provides a mechanism to block waiting for button
 // events.
 type ButtonWaiter struct {
 	Events <-chan ButtonEvent
 
-	f    *os.File
-	err  error
-	done chan struct{}
-	wg   sync.WaitGroup
+	fds  []int
+	keys []*KeyMap
+	wake *os.File
+
+	mu  sync.Mutex
+	err error
+
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+	closeErr error
 }
 
-// NewButtonWaiter returns a ButtonWaiter.
-func NewButtonWaiter() (*ButtonWaiter, error) {
-	ev, err := os.Open(ButtonPath)
+// NewButtonWaiter returns a ButtonWaiter reading events from the input
+// event devices at the given paths, decoding key codes with EV3KeyMap.
+// If no device is given, the device is found using ButtonDevice and its
+// key codes are decoded with the KeyMap of its InputDevice's ButtonInput.
+func NewButtonWaiter(device ...string) (*ButtonWaiter, error) {
+	if len(device) == 0 {
+		in, err := buttonInputIn(InputPath, probeInput)
+		if err != nil {
+			return nil, err
+		}
+		return NewButtonWaiterFrom(in)
+	}
+	inputs := make([]ButtonInput, len(device))
+	for i, path := range device {
+		inputs[i].Path = path
+	}
+	return NewButtonWaiterFrom(inputs...)
+}
+
+// NewButtonWaiterFrom returns a ButtonWaiter merging the events from the
+// given button inputs. An input that is removed or reaches the end of its
+// events is dropped, and the Events channel is closed when no inputs
+// remain.
+func NewButtonWaiterFrom(inputs ...ButtonInput) (*ButtonWaiter, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("ev3dev: no button inputs")
+	}
+	b := &ButtonWaiter{done: make(chan struct{})}
+	for _, in := range inputs {
+		fd, err := unix.Open(in.Path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err != nil {
+			b.closeInputs()
+			return nil, fmt.Errorf("ev3dev: failed to open button event device: %v", err)
+		}
+		b.fds = append(b.fds, fd)
+		b.keys = append(b.keys, in.keyMap())
+	}
+	r, w, err := os.Pipe()
 	if err != nil {
-		return nil, fmt.Errorf("ev3dev: failed to open button event device: %v", err)
+		b.closeInputs()
+		return nil, fmt.Errorf("ev3dev: failed to create button waiter wake pipe: %v", err)
 	}
+	b.wake = w
 
 	c := make(chan ButtonEvent)
-	b := &ButtonWaiter{Events: c, f: ev, done: make(chan struct{})}
+	b.Events = c
 
 	b.wg.Add(1)
 	go func() {
-		defer b.wg.Done()
-		var buf [16]byte
-		for {
-			select {
-			case <-b.done:
-				close(c)
-				return
-			default:
-				_, err := io.ReadFull(ev, buf[:])
-				if err != nil {
-					c <- ButtonEvent{Err: err}
-					continue
-				}
-				c <- getEvent(buf[:])
-			}
+		defer func() {
+			close(c)
+			r.Close()
+			b.wg.Done()
+		}()
+		err := b.run(c, r)
+		if err == nil {
+			return
+		}
+		b.mu.Lock()
+		b.err = err
+		b.mu.Unlock()
+		select {
+		case c <- ButtonEvent{Err: err}:
+		case <-b.done:
 		}
 	}()
 	return b, nil
 }
 
-func getEvent(buf []byte) ButtonEvent {
-	sec := binary.LittleEndian.Uint32(buf[:4])
-	usec := binary.LittleEndian.Uint32(buf[4:8])
-	return ButtonEvent{
-		Button:    keyTable[binary.LittleEndian.Uint16(buf[10:12])],
-		TimeStamp: time.Duration(time.Duration(sec)*time.Second + time.Duration(usec)*time.Microsecond),
-		Type:      uint(binary.LittleEndian.Uint16(buf[8:10])),
-		Value:     uint(binary.LittleEndian.Uint32(buf[12:16])),
+// run reads events from the button event devices and sends them on c until
+// the ButtonWaiter is closed, all the devices have gone or an error occurs.
+// The wake pipe r becomes readable when the ButtonWaiter is closed.
+func (b *ButtonWaiter) run(c chan<- ButtonEvent, r *os.File) error {
+	// The wake pipe is polled last. Inputs
+	// that have gone away are given a
+	// negative fd so they are ignored.
+	fds := make([]unix.PollFd, len(b.fds)+1)
+	for i, fd := range b.fds {
+		fds[i] = unix.PollFd{Fd: int32(fd), Events: unix.POLLIN}
 	}
+	wake := &fds[len(b.fds)]
+	*wake = unix.PollFd{Fd: int32(r.Fd()), Events: unix.POLLIN}
+	live := len(b.fds)
+
+	var buf [16 * inputEventSize]byte
+	for live > 0 {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("ev3dev: failed to poll button event device: %v", err)
+		}
+		if wake.Revents != 0 {
+			return nil
+		}
+		for i := range b.fds {
+			p := &fds[i]
+			if p.Fd < 0 || p.Revents == 0 {
+				continue
+			}
+			if p.Revents&unix.POLLNVAL != 0 {
+				return errors.New("ev3dev: invalid button event device")
+			}
+
+			n, err := unix.Read(b.fds[i], buf[:])
+			switch err {
+			case nil:
+			case unix.EAGAIN, unix.EINTR:
+				continue
+			case unix.ENODEV:
+				n = 0
+			default:
+				return fmt.Errorf("ev3dev: failed to read button event device: %v", err)
+			}
+			if n == 0 {
+				// The event source has gone away.
+				p.Fd = -1
+				live--
+				continue
+			}
+			for j := 0; j+inputEventSize <= n; j += inputEventSize {
+				select {
+				case c <- getEvent(buf[j:j+inputEventSize], b.keys[i]):
+				case <-b.done:
+					return nil
+				}
+			}
+		}
+	}
+	return nil
 }
 
-// Close closes the backing events source file and the Events channel.
-func (b *ButtonWaiter) Close() error {
+// Next returns the next button event, waiting until an event is available,
+// the event stream ends or ctx is done. If the stream has ended, Next returns
+// the error that ended it, or io.EOF if there was none.
+func (b *ButtonWaiter) Next(ctx context.Context) (ButtonEvent, error) {
 	select {
-	case <-b.done:
-		return nil
+	case <-ctx.Done():
+		return ButtonEvent{}, ctx.Err()
+	case e, ok := <-b.Events:
+		if !ok {
+			err := b.Err()
+			if err == nil {
+				err = io.EOF
+			}
+			return ButtonEvent{}, err
+		}
+		return e, e.Err
+	}
+}
+
+// Err returns the error that ended the event stream, if any.
+func (b *ButtonWaiter) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// inputEventSize is the size of a linux struct input_event on the host.
+// The struct is a struct timeval followed by the 16 bit type and code and
+// the 32 bit value of the event.
+const inputEventSize = int(unsafe.Sizeof(unix.Timeval{})) + 8
+
+// getEvent decodes the struct input_event held in buf. The length of buf
+// determines the size of the timeval fields, so buf must hold exactly one
+// event; 16 bytes on 32 bit platforms and 24 bytes on 64 bit platforms.
+func getEvent(buf []byte, m *KeyMap) ButtonEvent {
+	var sec, usec uint64
+	word := (len(buf) - 8) / 2
+	switch word {
+	case 4:
+		sec = uint64(binary.LittleEndian.Uint32(buf[:4]))
+		usec = uint64(binary.LittleEndian.Uint32(buf[4:8]))
+	case 8:
+		sec = binary.LittleEndian.Uint64(buf[:8])
+		usec = binary.LittleEndian.Uint64(buf[8:16])
 	default:
+		panic("ev3dev: invalid input event length")
+	}
+	buf = buf[2*word:]
+	e := ButtonEvent{
+		TimeStamp: time.Duration(sec)*time.Second + time.Duration(usec)*time.Microsecond,
+		Type:      uint(binary.LittleEndian.Uint16(buf[:2])),
+		Value:     uint(binary.LittleEndian.Uint32(buf[4:8])),
+	}
+	if e.Type == ev_key {
+		e.Button = m.Keys[uint(binary.LittleEndian.Uint16(buf[2:4]))]
+	}
+	return e
+}
+
+// Close closes the backing events source files and the Events channel.
+// Close does not wait for a button event and is safe to call more than
+// once and concurrently.
+func (b *ButtonWaiter) Close() error {
+	b.once.Do(func() {
 		close(b.done)
+		err := b.wake.Close()
 		b.wg.Wait()
-		return b.f.Close()
+		if cerr := b.closeInputs(); err == nil {
+			err = cerr
+		}
+		b.closeErr = err
+	})
+	return b.closeErr
+}
+
+// closeInputs closes the button event device files.
+func (b *ButtonWaiter) closeInputs() error {
+	var err error
+	for _, fd := range b.fds {
+		if cerr := unix.Close(fd); err == nil {
+			err = cerr
+		}
 	}
+	return err
 }
 
 // ButtonEvent is a button event, including the time of the event. The Err
-// value reflects any error state arising from detected the event.
+// value reflects any error state arising from detected the event. An event
+// with a non-nil Err is the last event sent by a ButtonWaiter.
 type ButtonEvent struct {
 	Button      Button
 	TimeStamp   time.Duration
@@ -187,8 +476,11 @@ func isSet(bit uint, buf []byte) bool {
 }
 
 const (
+	key_esc       = 1
 	key_backspace = 14
 	key_enter     = 28
+	key_space     = 57
+	key_kpenter   = 96
 	key_up        = 103
 	key_down      = 108
 	key_left      = 105
@@ -198,12 +490,3 @@ const (
 
 	keyBufLen = (key_max + 7) / 8
 )
-
-var keyTable = [key_max]Button{
-	key_backspace: Back,
-	key_left:      Left,
-	key_enter:     Middle,
-	key_right:     Right,
-	key_up:        Up,
-	key_down:      Down,
-}
@@ -5,8 +5,15 @@
 package ev3dev
 
 import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 var buttonPollingTests = []struct {
@@ -136,13 +143,27 @@ var buttonPollingTests = []struct {
 
 func TestButtonPolling(t *testing.T) {
 	for i, p := range buttonPollingTests {
-		got := getButton(p.buf)
+		got := getButton(p.buf, &EV3KeyMap)
 		if got != p.want {
 			t.Errorf("unexpected button for buffer %d: got:%d want:%d", i, got, p.want)
 		}
 	}
 }
 
+func TestButtonPollingKeyMap(t *testing.T) {
+	buf := keyBits(key_esc, key_space, key_up)
+	got := getButton(buf, &KeyboardKeyMap)
+	if want := Back | Middle | Up; got != want {
+		t.Errorf("unexpected button for keyboard state: got:%v want:%v", got, want)
+	}
+
+	m := KeyMap{Keys: map[uint]Button{30: Left, 32: Right, 57: Left | Right}}
+	got = getButton(keyBits(30, 57), &m)
+	if want := Left | Right; got != want {
+		t.Errorf("unexpected button for remapped state: got:%v want:%v", got, want)
+	}
+}
+
 var buttonEventTests = []struct {
 	buf  []byte
 	want ButtonEvent
@@ -307,9 +328,274 @@ var buttonEventTests = []struct {
 
 func TestButtonEvents(t *testing.T) {
 	for i, e := range buttonEventTests {
-		got := getEvent(e.buf)
+		got := getEvent(e.buf, &EV3KeyMap)
 		if got != e.want {
 			t.Errorf("unexpected button event for buffer %d: got:%+v want:%+v", i, got, e.want)
 		}
 	}
 }
+
+var buttonEvent64Tests = []struct {
+	buf  []byte
+	want ButtonEvent
+}{
+	{
+		buf: []byte{
+			0x4c, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x40, 0x42, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x69, 0x00, 0x01, 0x00, 0x00, 0x00,
+		},
+		want: ButtonEvent{
+			Button:    Left,
+			TimeStamp: time.Hour + time.Minute + time.Second,
+
+			Type: 1, Value: 1,
+		},
+	},
+	{
+		buf: []byte{
+			0x4c, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x40, 0x42, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		},
+		want: ButtonEvent{
+			Button:    0, // Undepress.
+			TimeStamp: time.Hour + time.Minute + time.Second,
+
+			Type: 0, Value: 0,
+		},
+	},
+}
+
+func TestButtonEvents64(t *testing.T) {
+	for i, e := range buttonEvent64Tests {
+		got := getEvent(e.buf, &EV3KeyMap)
+		if got != e.want {
+			t.Errorf("unexpected button event for buffer %d: got:%+v want:%+v", i, got, e.want)
+		}
+	}
+}
+
+// hostEvent returns the 32 bit struct input_event in buf
+// in the layout of the host.
+func hostEvent(buf []byte) []byte {
+	word := (inputEventSize - 8) / 2
+	b := make([]byte, inputEventSize)
+	copy(b, buf[:4])
+	copy(b[word:], buf[4:8])
+	copy(b[2*word:], buf[8:16])
+	return b
+}
+
+func TestButtonWaiter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ev3dev-buttons")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "event0")
+	err = unix.Mkfifo(path, 0600)
+	if err != nil {
+		t.Fatalf("failed to create event fifo: %v", err)
+	}
+
+	w, err := NewButtonWaiter(path)
+	if err != nil {
+		t.Fatalf("unexpected error creating button waiter: %v", err)
+	}
+	defer w.Close()
+	dev, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open event fifo for writing: %v", err)
+	}
+	defer dev.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err = w.Next(ctx)
+	cancel()
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error waiting without events: got:%v want:%v", err, context.DeadlineExceeded)
+	}
+
+	for _, e := range buttonEventTests {
+		_, err = dev.Write(hostEvent(e.buf))
+		if err != nil {
+			t.Fatalf("failed to write event: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		got, err := w.Next(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("unexpected error waiting for event: %v", err)
+		}
+		if got != e.want {
+			t.Errorf("unexpected button event: got:%+v want:%+v", got, e.want)
+		}
+	}
+
+	// Events read together are all decoded.
+	var burst []byte
+	for _, e := range buttonEventTests {
+		burst = append(burst, hostEvent(e.buf)...)
+	}
+	_, err = dev.Write(burst)
+	if err != nil {
+		t.Fatalf("failed to write events: %v", err)
+	}
+	for _, e := range buttonEventTests {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		got, err := w.Next(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("unexpected error waiting for event: %v", err)
+		}
+		if got != e.want {
+			t.Errorf("unexpected button event in burst: got:%+v want:%+v", got, e.want)
+		}
+	}
+
+	// Closing the event source ends the stream.
+	dev.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	_, err = w.Next(ctx)
+	cancel()
+	if err != io.EOF {
+		t.Errorf("unexpected error after event source closed: got:%v want:%v", err, io.EOF)
+	}
+}
+
+func TestButtonWaiterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ev3dev-buttons")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "event0")
+	err = unix.Mkfifo(path, 0600)
+	if err != nil {
+		t.Fatalf("failed to create event fifo: %v", err)
+	}
+
+	w, err := NewButtonWaiter(path)
+	if err != nil {
+		t.Fatalf("unexpected error creating button waiter: %v", err)
+	}
+	dev, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open event fifo for writing: %v", err)
+	}
+	defer dev.Close()
+
+	// Concurrent calls to Close must not panic.
+	closed := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() { closed <- w.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-closed:
+			if err != nil {
+				t.Errorf("unexpected error closing button waiter: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for close")
+		}
+	}
+	if _, ok := <-w.Events; ok {
+		t.Error("unexpected event after close")
+	}
+	err = w.Close()
+	if err != nil {
+		t.Errorf("unexpected error closing button waiter twice: %v", err)
+	}
+}
+
+func TestButtonWaiterMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ev3dev-buttons")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputs := []ButtonInput{
+		{Path: filepath.Join(dir, "event0")},
+		{Path: filepath.Join(dir, "event1"), Keys: &KeyboardKeyMap},
+	}
+	for _, in := range inputs {
+		err = unix.Mkfifo(in.Path, 0600)
+		if err != nil {
+			t.Fatalf("failed to create event fifo: %v", err)
+		}
+	}
+	w, err := NewButtonWaiterFrom(inputs...)
+	if err != nil {
+		t.Fatalf("unexpected error creating button waiter: %v", err)
+	}
+	defer w.Close()
+	devs := make([]*os.File, len(inputs))
+	for i, in := range inputs {
+		devs[i], err = os.OpenFile(in.Path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("failed to open event fifo for writing: %v", err)
+		}
+		defer devs[i].Close()
+	}
+
+	// Key press of Escape, then a misc scan
+	// event with the code of Escape.
+	esc := []byte{
+		0x4c, 0x0e, 0x00, 0x00, 0x40, 0x42, 0x0f, 0x00,
+		0x01, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00,
+	}
+	scan := []byte{
+		0x4c, 0x0e, 0x00, 0x00, 0x40, 0x42, 0x0f, 0x00,
+		0x04, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00,
+	}
+	mergeTests := []struct {
+		dev  int
+		buf  []byte
+		want ButtonEvent
+	}{
+		{dev: 0, buf: buttonEventTests[1].buf, want: buttonEventTests[1].want},
+		{dev: 1, buf: buttonEventTests[3].buf, want: buttonEventTests[3].want},
+		{dev: 0, buf: esc, want: ButtonEvent{TimeStamp: time.Hour + time.Minute + time.Second, Type: 1, Value: 1}},
+		{dev: 1, buf: esc, want: ButtonEvent{Button: Back, TimeStamp: time.Hour + time.Minute + time.Second, Type: 1, Value: 1}},
+		{dev: 1, buf: scan, want: ButtonEvent{TimeStamp: time.Hour + time.Minute + time.Second, Type: 4, Value: 1}},
+	}
+	for _, e := range mergeTests {
+		_, err = devs[e.dev].Write(hostEvent(e.buf))
+		if err != nil {
+			t.Fatalf("failed to write event: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		got, err := w.Next(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("unexpected error waiting for event: %v", err)
+		}
+		if got != e.want {
+			t.Errorf("unexpected button event from device %d: got:%+v want:%+v", e.dev, got, e.want)
+		}
+	}
+
+	// Closing one event source leaves the other.
+	devs[0].Close()
+	_, err = devs[1].Write(hostEvent(buttonEventTests[11].buf))
+	if err != nil {
+		t.Fatalf("failed to write event: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	got, err := w.Next(ctx)
+	cancel()
+	if err != nil || got != buttonEventTests[11].want {
+		t.Errorf("unexpected button event after closing one device: got:%+v err:%v", got, err)
+	}
+
+	devs[1].Close()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	_, err = w.Next(ctx)
+	cancel()
+	if err != io.EOF {
+		t.Errorf("unexpected error after all event sources closed: got:%v want:%v", err, io.EOF)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// attrBufLen is the maximum length of a sysfs attribute.
+const attrBufLen = 4096
+
+// pread and pwrite are the positioned I/O functions used by CachedBackend.
+// They are variables to allow tests to simulate device removal.
+var (
+	pread  = unix.Pread
+	pwrite = unix.Pwrite
+)
+
+// CachedBackend is a Backend that keeps attribute files open between
+// accesses and reads and writes them with pread(2) and pwrite(2), avoiding
+// the cost of opening and closing a file for each attribute access.
+//
+// A cached handle is discarded and the file reopened when an access fails
+// with ENODEV or ENOENT, as happens when the device holding the attribute
+// is removed. Writes do not truncate the file, so CachedBackend is only
+// suitable for sysfs attribute files.
+//
+// CachedBackend is safe for concurrent use.
+type CachedBackend struct {
+	mu      sync.Mutex
+	readers map[string]*cachedFile
+	writers map[string]*cachedFile
+
+	buf sync.Pool
+}
+
+// cachedFile is a cached attribute file handle. The file is nil when
+// the handle has been closed.
+type cachedFile struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewCachedBackend returns a new CachedBackend with no open files.
+func NewCachedBackend() *CachedBackend {
+	return &CachedBackend{
+		readers: make(map[string]*cachedFile),
+		writers: make(map[string]*cachedFile),
+		buf:     sync.Pool{New: func() interface{} { return make([]byte, attrBufLen) }},
+	}
+}
+
+// Read returns the contents of the attribute file at path.
+func (b *CachedBackend) Read(path string) ([]byte, error) {
+	buf := b.buf.Get().([]byte)
+	defer b.buf.Put(buf)
+
+	var n int
+	err := b.do(b.readers, path, os.O_RDONLY, func(fd int) (err error) {
+		n, err = pread(fd, buf, 0)
+		return err
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "read", Path: path, Err: err}
+	}
+	return append([]byte(nil), buf[:n]...), nil
+}
+
+// Write writes data to the attribute file at path.
+func (b *CachedBackend) Write(path string, data []byte) error {
+	err := b.do(b.writers, path, os.O_WRONLY, func(fd int) error {
+		_, err := pwrite(fd, data, 0)
+		return err
+	})
+	if err != nil {
+		return &os.PathError{Op: "write", Path: path, Err: err}
+	}
+	return nil
+}
+
+// List returns the names of the entries in the directory at path.
+// Directory listings are not cached.
+func (b *CachedBackend) List(path string) ([]string, error) {
+	return SysfsBackend{}.List(path)
+}
+
+// Close closes all the cached files. The CachedBackend may continue to be
+// used after Close, in which case files are reopened as needed.
+func (b *CachedBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var err error
+	for _, files := range []map[string]*cachedFile{b.readers, b.writers} {
+		for path, h := range files {
+			if cerr := h.close(); err == nil {
+				err = cerr
+			}
+			delete(files, path)
+		}
+	}
+	return err
+}
+
+// do calls fn with the file descriptor of the cached file for path in files,
+// opening the file with flag if necessary. If the file has been removed,
+// the handle is discarded and fn is retried once with a newly opened file.
+// The returned error is the underlying errno or open error.
+func (b *CachedBackend) do(files map[string]*cachedFile, path string, flag int, fn func(fd int) error) error {
+	var err error
+	for try := 0; try < 2; try++ {
+		var h *cachedFile
+		h, err = b.handle(files, path, flag)
+		if err != nil {
+			return underlying(err)
+		}
+		h.mu.Lock()
+		if h.f == nil {
+			// The handle was closed after we got it.
+			h.mu.Unlock()
+			err = syscall.EBADF
+			continue
+		}
+		err = fn(int(h.f.Fd()))
+		h.mu.Unlock()
+		if !isGone(err) {
+			return err
+		}
+		b.forget(files, path, h)
+	}
+	return err
+}
+
+// handle returns the cached file for path in files, opening
+// the file with flag if it is not already open.
+func (b *CachedBackend) handle(files map[string]*cachedFile, path string, flag int) (*cachedFile, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := files[path]
+	if ok {
+		return h, nil
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	h = &cachedFile{f: f}
+	files[path] = h
+	return h, nil
+}
+
+// forget closes h and removes it from files if it is still
+// the cached file for path.
+func (b *CachedBackend) forget(files map[string]*cachedFile, path string, h *cachedFile) {
+	b.mu.Lock()
+	if files[path] == h {
+		delete(files, path)
+	}
+	b.mu.Unlock()
+	h.close()
+}
+
+func (h *cachedFile) close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.f == nil {
+		return nil
+	}
+	err := h.f.Close()
+	h.f = nil
+	return err
+}
+
+// isGone returns whether err indicates that the file
+// or its device no longer exists.
+func isGone(err error) bool {
+	return err == syscall.ENODEV || err == syscall.ENOENT
+}
+
+// underlying returns the error underlying an *os.PathError.
+func underlying(err error) error {
+	if perr, ok := err.(*os.PathError); ok {
+		return perr.Err
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func newCachedTestDir(t testing.TB) (dir string, done func()) {
+	dir, err := ioutil.TempDir("", "ev3dev-cached")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	for attr, val := range map[string]string{
+		position: "100\n",
+		command:  "\n",
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, attr), []byte(val), 0644)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("failed to write %s: %v", attr, err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestCachedBackend(t *testing.T) {
+	dir, done := newCachedTestDir(t)
+	defer done()
+
+	b := NewCachedBackend()
+	defer b.Close()
+
+	path := filepath.Join(dir, position)
+	for i := 0; i < 3; i++ {
+		got, err := b.Read(path)
+		if err != nil || string(got) != "100\n" {
+			t.Errorf("unexpected read result: got:%q want:%q (err=%v)", got, "100\n", err)
+		}
+	}
+	if len(b.readers) != 1 {
+		t.Errorf("unexpected number of cached readers: got:%d want:1", len(b.readers))
+	}
+	err := b.Write(path, []byte("200"))
+	if err != nil {
+		t.Errorf("unexpected error writing: %v", err)
+	}
+	got, err := b.Read(path)
+	if err != nil || string(got) != "200\n" {
+		t.Errorf("unexpected read result after write: got:%q want:%q (err=%v)", got, "200\n", err)
+	}
+
+	_, err = b.Read(filepath.Join(dir, "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error reading missing file: got:%v want not exist error", err)
+	}
+	if len(b.readers) != 1 {
+		t.Errorf("unexpected number of cached readers: got:%d want:1", len(b.readers))
+	}
+
+	err = b.Close()
+	if err != nil {
+		t.Errorf("unexpected error closing: %v", err)
+	}
+	if len(b.readers) != 0 || len(b.writers) != 0 {
+		t.Errorf("unexpected cached files after close: readers=%d writers=%d", len(b.readers), len(b.writers))
+	}
+	got, err = b.Read(path)
+	if err != nil || string(got) != "200\n" {
+		t.Errorf("unexpected read result after close: got:%q want:%q (err=%v)", got, "200\n", err)
+	}
+}
+
+func TestCachedBackendInvalidation(t *testing.T) {
+	dir, done := newCachedTestDir(t)
+	defer done()
+
+	defer func(fn func(int, []byte, int64) (int, error)) { pread = fn }(pread)
+
+	b := NewCachedBackend()
+	defer b.Close()
+
+	path := filepath.Join(dir, position)
+	_, err := b.Read(path)
+	if err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+	old := b.readers[path]
+
+	// Simulate the device being replaced by failing
+	// the next read on the cached handle.
+	gone := true
+	pread = func(fd int, p []byte, off int64) (int, error) {
+		if gone {
+			gone = false
+			return 0, syscall.ENODEV
+		}
+		return syscall.Pread(fd, p, off)
+	}
+	got, err := b.Read(path)
+	if err != nil || string(got) != "100\n" {
+		t.Errorf("unexpected read result after replacement: got:%q want:%q (err=%v)", got, "100\n", err)
+	}
+	if b.readers[path] == old {
+		t.Error("stale file handle not discarded")
+	}
+	if old.f != nil {
+		t.Error("stale file handle not closed")
+	}
+
+	// Simulate the device being removed.
+	gone = true
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatalf("failed to remove attribute: %v", err)
+	}
+	_, err = b.Read(path)
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error after removal: got:%v want not exist error", err)
+	}
+	if _, ok := b.readers[path]; ok {
+		t.Error("file handle for removed device not discarded")
+	}
+}
+
+func benchmarkRead(b *testing.B, be Backend) {
+	dir, done := newCachedTestDir(b)
+	defer done()
+	path := filepath.Join(dir, position)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := be.Read(path)
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkSysfsBackendRead(b *testing.B) { benchmarkRead(b, SysfsBackend{}) }
+func BenchmarkCachedBackendRead(b *testing.B) {
+	be := NewCachedBackend()
+	defer be.Close()
+	benchmarkRead(b, be)
+}
+
+func benchmarkWrite(b *testing.B, be Backend) {
+	dir, done := newCachedTestDir(b)
+	defer done()
+	path := filepath.Join(dir, command)
+	data := []byte("run-forever")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := be.Write(path, data)
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkSysfsBackendWrite(b *testing.B) { benchmarkWrite(b, SysfsBackend{}) }
+func BenchmarkCachedBackendWrite(b *testing.B) {
+	be := NewCachedBackend()
+	defer be.Close()
+	benchmarkWrite(b, be)
+}
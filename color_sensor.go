@@ -0,0 +1,205 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// ColorSensorDriver is the driver name of the EV3 color sensor.
+const ColorSensorDriver = "lego-ev3-color"
+
+// Modes of the lego-ev3-color driver.
+const (
+	colorReflect = "COL-REFLECT"
+	colorAmbient = "COL-AMBIENT"
+	colorColor   = "COL-COLOR"
+	colorRGBRaw  = "RGB-RAW"
+)
+
+// ColorSensor represents a handle to a lego-ev3-color sensor. The
+// ColorSensor switches the mode of the underlying Sensor as required
+// by each reading method, only writing the mode attribute when the
+// requested mode differs from the last known mode.
+//
+// Changing the mode of the embedded Sensor directly invalidates the
+// mode known to the ColorSensor; Sync must be called after doing this.
+type ColorSensor struct {
+	*Sensor
+
+	// mode is the last known
+	// mode of the Sensor.
+	mode string
+}
+
+// ColorSensorFor returns a ColorSensor for the given ev3 port name. If the
+// sensor on the port is not a lego-ev3-color sensor, a nil ColorSensor is
+// returned with a DriverMismatch error.
+// If port is empty, the first lego-ev3-color sensor is returned.
+func ColorSensorFor(port string) (*ColorSensor, error) {
+	s, err := SensorFor(port, ColorSensorDriver)
+	if err != nil {
+		return nil, err
+	}
+	return &ColorSensor{Sensor: s}, nil
+}
+
+// Next returns a ColorSensor for the next lego-ev3-color sensor.
+func (c *ColorSensor) Next() (*ColorSensor, error) {
+	s, err := c.Sensor.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &ColorSensor{Sensor: s}, nil
+}
+
+// Sync discards the last known mode of the ColorSensor so that it is
+// reread from the device on the next reading.
+func (c *ColorSensor) Sync() *ColorSensor {
+	c.mode = ""
+	return c
+}
+
+// setMode sets the mode of the underlying Sensor if it is not already m.
+func (c *ColorSensor) setMode(m string) error {
+	if c.Sensor.err != nil {
+		return c.Sensor.Err()
+	}
+	if c.mode == "" {
+		mode, err := c.Sensor.Mode()
+		if err != nil {
+			return err
+		}
+		c.mode = mode
+	}
+	if c.mode == m {
+		return nil
+	}
+	err := c.Sensor.SetMode(m).Err()
+	if err != nil {
+		c.mode = ""
+		return err
+	}
+	c.mode = m
+	return nil
+}
+
+// Reflected returns the reflected light intensity measured by the
+// ColorSensor in percent, using the COL-REFLECT mode.
+func (c *ColorSensor) Reflected() (int, error) {
+	err := c.setMode(colorReflect)
+	if err != nil {
+		return -1, err
+	}
+	return intFrom(attributeOf(c.Sensor, value+"0"))
+}
+
+// Ambient returns the ambient light intensity measured by the
+// ColorSensor in percent, using the COL-AMBIENT mode.
+func (c *ColorSensor) Ambient() (int, error) {
+	err := c.setMode(colorAmbient)
+	if err != nil {
+		return -1, err
+	}
+	return intFrom(attributeOf(c.Sensor, value+"0"))
+}
+
+// Color returns the color detected by the ColorSensor, using the
+// COL-COLOR mode.
+func (c *ColorSensor) Color() (SensorColor, error) {
+	err := c.setMode(colorColor)
+	if err != nil {
+		return NoColor, err
+	}
+	v, err := intFrom(attributeOf(c.Sensor, value+"0"))
+	if err != nil {
+		return NoColor, err
+	}
+	if v < int(NoColor) || int(Brown) < v {
+		return NoColor, newValueOutOfRangeError(c.Sensor, value+"0", v, int(NoColor), int(Brown))
+	}
+	return SensorColor(v), nil
+}
+
+// RGB returns the raw red, green and blue reflected light components
+// measured by the ColorSensor, using the RGB-RAW mode.
+func (c *ColorSensor) RGB() (RawRGB, error) {
+	err := c.setMode(colorRGBRaw)
+	if err != nil {
+		return RawRGB{}, err
+	}
+	var rgb [3]uint16
+	for i := range rgb {
+		attr := fmt.Sprint(value, i)
+		v, err := intFrom(attributeOf(c.Sensor, attr))
+		if err != nil {
+			return RawRGB{}, err
+		}
+		if v < 0 || MaxRawRGB < v {
+			return RawRGB{}, newValueOutOfRangeError(c.Sensor, attr, v, 0, MaxRawRGB)
+		}
+		rgb[i] = uint16(v)
+	}
+	return RawRGB{R: rgb[0], G: rgb[1], B: rgb[2]}, nil
+}
+
+// SensorColor is a color detected by a ColorSensor in the COL-COLOR mode.
+type SensorColor int
+
+const (
+	NoColor SensorColor = iota
+	Black
+	Blue
+	Green
+	Yellow
+	Red
+	White
+	Brown
+)
+
+var sensorColorNames = [...]string{
+	NoColor: "none",
+	Black:   "black",
+	Blue:    "blue",
+	Green:   "green",
+	Yellow:  "yellow",
+	Red:     "red",
+	White:   "white",
+	Brown:   "brown",
+}
+
+// String satisfies the fmt.Stringer interface.
+func (c SensorColor) String() string {
+	if c < NoColor || Brown < c {
+		return fmt.Sprintf("SensorColor(%d)", int(c))
+	}
+	return sensorColorNames[c]
+}
+
+// MaxRawRGB is the maximum value of a RawRGB component.
+const MaxRawRGB = 1020
+
+// RawRGB is a raw color reading from a ColorSensor in the RGB-RAW mode.
+// Component values are in the range [0, MaxRawRGB]. RawRGB satisfies
+// the image/color.Color interface.
+type RawRGB struct {
+	R, G, B uint16
+}
+
+var _ color.Color = RawRGB{}
+
+// RGBA returns the alpha-premultiplied red, green, blue and alpha values
+// of the color, scaling the raw components to the full 16-bit range.
+func (c RawRGB) RGBA() (r, g, b, a uint32) {
+	return scaleRaw(c.R), scaleRaw(c.G), scaleRaw(c.B), 0xffff
+}
+
+func scaleRaw(v uint16) uint32 {
+	if v >= MaxRawRGB {
+		return 0xffff
+	}
+	return uint32(v) * 0xffff / MaxRawRGB
+}
@@ -0,0 +1,172 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	. "github.com/ev3go/ev3dev"
+)
+
+func (s *sensor) setValues(v ...string) {
+	s.mu.Lock()
+	s._values = v
+	s.mu.Unlock()
+}
+
+func (s *sensor) mode() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s._mode
+}
+
+func TestColorSensor(t *testing.T) {
+	conn := []sensorConn{
+		{
+			id: 2,
+			sensor: &sensor{
+				address: "in1",
+				driver:  ColorSensorDriver,
+
+				_modes: []string{"COL-REFLECT", "COL-AMBIENT", "COL-COLOR", "REF-RAW", "RGB-RAW", "COL-CAL"},
+				_mode:  "COL-REFLECT",
+
+				t: t,
+			},
+		},
+		{
+			id: 3,
+			sensor: &sensor{
+				address: "in2",
+				driver:  "lego-ev3-gyro",
+
+				_modes: []string{"GYRO-ANG", "GYRO-RATE"},
+				_mode:  "GYRO-ANG",
+
+				t: t,
+			},
+		},
+	}
+
+	fs := sensorsysfs(conn...)
+	unmount := serve(fs, t)
+	defer unmount()
+
+	invalidate := func(s *ColorSensor, attrs ...string) {
+		for _, a := range attrs {
+			err := fs.InvalidatePath(filepath.Join(s.Path(), s.String(), a))
+			if err != nil {
+				t.Fatalf("unexpected error invalidating %s: %v", a, err)
+			}
+		}
+	}
+
+	t.Run("new ColorSensor", func(t *testing.T) {
+		_, err := ColorSensorFor(conn[0].sensor.address)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		got, err := ColorSensorFor(conn[1].sensor.address)
+		if _, ok := err.(DriverMismatch); !ok {
+			t.Errorf("unexpected error type for driver mismatch: got:%T want:%T", err, DriverMismatch{})
+		}
+		if got != nil {
+			t.Errorf("unexpected non-nil ColorSensor for mismatched driver: %v", got)
+		}
+	})
+
+	t.Run("Reflected", func(t *testing.T) {
+		s, err := ColorSensorFor(conn[0].sensor.address)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []int{0, 37, 100} {
+			conn[0].sensor.setValues(fmt.Sprint(want))
+			invalidate(s, ValueName+"0")
+			got, err := s.Reflected()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if got != want {
+				t.Errorf("unexpected reflected value: got:%d want:%d", got, want)
+			}
+			if mode := conn[0].sensor.mode(); mode != "COL-REFLECT" {
+				t.Errorf("unexpected mode: got:%q want:%q", mode, "COL-REFLECT")
+			}
+		}
+	})
+
+	t.Run("Ambient", func(t *testing.T) {
+		s, err := ColorSensorFor(conn[0].sensor.address)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		conn[0].sensor.setValues("12")
+		invalidate(s, ValueName+"0")
+		got, err := s.Ambient()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if got != 12 {
+			t.Errorf("unexpected ambient value: got:%d want:%d", got, 12)
+		}
+		if mode := conn[0].sensor.mode(); mode != "COL-AMBIENT" {
+			t.Errorf("unexpected mode: got:%q want:%q", mode, "COL-AMBIENT")
+		}
+	})
+
+	t.Run("Color", func(t *testing.T) {
+		s, err := ColorSensorFor(conn[0].sensor.address)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for want := NoColor; want <= Brown; want++ {
+			conn[0].sensor.setValues(fmt.Sprint(int(want)))
+			invalidate(s, ValueName+"0")
+			got, err := s.Color()
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if got != want {
+				t.Errorf("unexpected color value: got:%v want:%v", got, want)
+			}
+		}
+		conn[0].sensor.setValues("8")
+		invalidate(s, ValueName+"0")
+		_, err = s.Color()
+		if err == nil {
+			t.Error("expected error for invalid color value")
+		}
+		if mode := conn[0].sensor.mode(); mode != "COL-COLOR" {
+			t.Errorf("unexpected mode: got:%q want:%q", mode, "COL-COLOR")
+		}
+	})
+
+	t.Run("RGB", func(t *testing.T) {
+		s, err := ColorSensorFor(conn[0].sensor.address)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		conn[0].sensor.setValues("1020", "510", "0")
+		invalidate(s, ValueName+"0", ValueName+"1", ValueName+"2")
+		got, err := s.RGB()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		want := RawRGB{R: 1020, G: 510, B: 0}
+		if got != want {
+			t.Errorf("unexpected RGB value: got:%v want:%v", got, want)
+		}
+		if mode := conn[0].sensor.mode(); mode != "RGB-RAW" {
+			t.Errorf("unexpected mode: got:%q want:%q", mode, "RGB-RAW")
+		}
+		r, g, b, a := got.RGBA()
+		if r != 0xffff || g != 0x7fff || b != 0 || a != 0xffff {
+			t.Errorf("unexpected RGBA value: got:%#x %#x %#x %#x", r, g, b, a)
+		}
+	})
+}
@@ -22,10 +22,10 @@ package ev3dev
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -33,6 +33,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -46,14 +47,23 @@ const (
 )
 
 // prefix is the filesystem root prefix.
-// Currently it is used only for testing.
+// It is used for testing and set by SetPrefix.
 var prefix = ""
 
+// SetPrefix sets the filesystem root prefix used to locate device class
+// and attribute files. It allows programs to run against a device tree
+// rooted in a directory other than the system root, such as one provided
+// by the sim package. SetPrefix must not be called concurrently with
+// device access.
+func SetPrefix(p string) { prefix = p }
+
 const (
 	// LEDPath is the path to the ev3 LED file system.
 	LEDPath = "/sys/class/leds"
 
-	// ButtonPath is the path to the ev3 button events.
+	// ButtonPath is the path to the ev3 button events on
+	// older ev3dev kernels. ButtonDevice should be used to
+	// find the button event device.
 	ButtonPath = "/dev/input/by-path/platform-gpio-keys.0-event"
 
 	// LegoPortPath is the path to the ev3 lego-port file system.
@@ -240,6 +250,21 @@ var canPoll = true
 // Wait will not set the error state of the StaterDevice, but will clear and
 // return it if it is not nil.
 func Wait(d StaterDevice, mask, want, not MotorState, any bool, timeout time.Duration) (stat MotorState, ok bool, err error) {
+	return wait(context.Background(), d, mask, want, not, any, timeout)
+}
+
+// WaitContext blocks until the wanted motor state under the motor state mask
+// is reached, or the context is done. The semantics of mask, want, not and
+// any are the same as for Wait. If the context is done before the wanted
+// motor state is reached, the last read motor state is returned with
+// ctx.Err().
+// WaitContext will not set the error state of the StaterDevice, but will
+// clear and return it if it is not nil.
+func WaitContext(ctx context.Context, d StaterDevice, mask, want, not MotorState, any bool) (stat MotorState, ok bool, err error) {
+	return wait(ctx, d, mask, want, not, any, -1)
+}
+
+func wait(ctx context.Context, d StaterDevice, mask, want, not MotorState, any bool, timeout time.Duration) (stat MotorState, ok bool, err error) {
 	// We use a direct implementation of the State method here
 	// to ensure we are polling on the same file as we are reading
 	// from. Also, since we are potentially probing the state
@@ -248,22 +273,52 @@ func Wait(d StaterDevice, mask, want, not MotorState, any bool, timeout time.Dur
 	// This also allows us to test the code, which would not
 	// otherwise be possible since sysiphus cannot do POLLPRI
 	// polling, due to limitations in FUSE.
+	//
+	// Backends other than the file system cannot be polled,
+	// so for those we read the state through the Backend on
+	// each iteration.
 
 	// Check if we can proceed.
 	err = d.Err()
 	if err != nil {
 		return 0, false, err
 	}
-
-	path := filepath.Join(d.Path(), d.String(), state)
-	f, err := os.Open(path)
+	err = ctx.Err()
 	if err != nil {
 		return 0, false, err
 	}
-	defer f.Close()
+
+	var (
+		f        *os.File
+		usePoll  = canPoll
+		getState func() (MotorState, error)
+	)
+	if isSysfs() {
+		path := filepath.Join(d.Path(), d.String(), state)
+		f, err = os.Open(path)
+		if err != nil {
+			return 0, false, err
+		}
+		defer f.Close()
+		getState = func() (MotorState, error) { return motorState(d, f) }
+	} else {
+		// Read the state directly rather than with
+		// attributeOf so that the error state of d,
+		// which may be in use by another goroutine,
+		// is not cleared.
+		usePoll = false
+		path := filepath.Join(d.Path(), d.String(), state)
+		getState = func() (MotorState, error) {
+			b, err := backend.Read(path)
+			if err != nil {
+				return 0, newAttrOpError(d, state, "", "read", err)
+			}
+			return stateFrom(d, string(chomp(b)), state, nil)
+		}
+	}
 
 	// See if we can exit early.
-	stat, err = motorState(d, f)
+	stat, err = getState()
 	if err != nil {
 		return stat, false, err
 	}
@@ -272,28 +327,72 @@ func Wait(d StaterDevice, mask, want, not MotorState, any bool, timeout time.Dur
 	}
 
 	var fds []unix.PollFd
-	if canPoll {
+	if usePoll {
 		fds = []unix.PollFd{{Fd: int32(f.Fd()), Events: unix.POLLIN}}
 
+		// If the context can be cancelled, add a wake-up
+		// pipe to the poll set so that we can return as
+		// soon as the context is done.
+		if done := ctx.Done(); done != nil {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return stat, false, err
+			}
+			defer r.Close()
+			fds = append(fds, unix.PollFd{Fd: int32(r.Fd()), Events: unix.POLLIN})
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer w.Close()
+				select {
+				case <-done:
+					w.Write([]byte{0})
+				case <-stop:
+				}
+			}()
+			defer func() {
+				close(stop)
+				wg.Wait()
+			}()
+		}
+
 		// Read a single byte to mark f as unchanged.
 		f.ReadAt([]byte{0}, 0)
 	}
 
+	relax := time.NewTimer(0)
+	if !relax.Stop() {
+		<-relax.C
+	}
+	defer relax.Stop()
+
 	end := time.Now().Add(timeout)
 	for timeout < 0 || time.Since(end) < 0 {
-		if canPoll {
+		if usePoll {
 			_timeout := timeout
 			if timeout >= 0 {
 				if remain := end.Sub(time.Now()); remain < timeout {
 					_timeout = remain
 				}
 			}
-			n, err := unix.Poll(fds, int(_timeout/time.Millisecond))
+			ms := -1
+			if _timeout >= 0 {
+				// Round up so that poll does not
+				// return before the deadline.
+				ms = int((_timeout + time.Millisecond - 1) / time.Millisecond)
+			}
+			n, err := unix.Poll(fds, ms)
+			if len(fds) > 1 && fds[1].Revents != 0 {
+				return stat, false, ctx.Err()
+			}
 			if n == 0 {
-				return 0, false, err
+				return stat, false, err
 			}
 		}
-		stat, err = motorState(d, f)
+		stat, err = getState()
 		if err != nil {
 			return stat, false, err
 		}
@@ -301,11 +400,16 @@ func Wait(d StaterDevice, mask, want, not MotorState, any bool, timeout time.Dur
 			return stat, true, nil
 		}
 
-		relax := 50 * time.Millisecond
-		if remain := end.Sub(time.Now()); remain < relax {
-			relax = remain / 2
+		pause := 50 * time.Millisecond
+		if remain := end.Sub(time.Now()); timeout >= 0 && remain < pause {
+			pause = remain / 2
+		}
+		relax.Reset(pause)
+		select {
+		case <-ctx.Done():
+			return stat, false, ctx.Err()
+		case <-relax.C:
 		}
-		time.Sleep(relax)
 	}
 
 	return stat, false, nil
@@ -415,7 +519,21 @@ func FindAfter(d, dst Device, driver string) error {
 
 // IsConnected returns whether the Device is connected.
 func IsConnected(d Device) (ok bool, err error) {
-	_, err = os.Stat(fmt.Sprintf(d.Path()+"/%s", d))
+	path := filepath.Join(d.Path(), d.String())
+	if isSysfs() {
+		_, err = os.Stat(path)
+	} else {
+		var names []string
+		names, err = backend.List(d.Path())
+		for _, n := range names {
+			if n == d.String() {
+				return true, nil
+			}
+		}
+		if err == nil {
+			return false, nil
+		}
+	}
 	if err == nil {
 		return true, nil
 	}
@@ -427,7 +545,7 @@ func IsConnected(d Device) (ok bool, err error) {
 
 // AddressOf returns the port address of the Device.
 func AddressOf(d Device) (string, error) {
-	b, err := ioutil.ReadFile(fmt.Sprintf(d.Path()+"/%s/"+address, d))
+	b, err := backend.Read(filepath.Join(d.Path(), d.String(), address))
 	if err != nil {
 		return "", fmt.Errorf("ev3dev: failed to read %s address: %v", d.Type(), err)
 	}
@@ -436,7 +554,7 @@ func AddressOf(d Device) (string, error) {
 
 // DriverFor returns the driver name for the Device.
 func DriverFor(d Device) (string, error) {
-	b, err := ioutil.ReadFile(fmt.Sprintf(d.Path()+"/%s/"+driverName, d))
+	b, err := backend.Read(filepath.Join(d.Path(), d.String(), driverName))
 	if err != nil {
 		return "", fmt.Errorf("ev3dev: failed to read %s driver name: %v", d.Type(), err)
 	}
@@ -466,7 +584,7 @@ func deviceIDFor(port, driver string, d Device, after int) (int, error) {
 				continue
 			}
 			path := filepath.Join(d.Path(), device.name, driverName)
-			b, err := ioutil.ReadFile(path)
+			b, err := backend.Read(path)
 			if os.IsNotExist(err) {
 				// If the device disappeared
 				// try the next one.
@@ -482,7 +600,7 @@ func deviceIDFor(port, driver string, d Device, after int) (int, error) {
 		}
 
 		path := filepath.Join(d.Path(), device.name, address)
-		b, err := ioutil.ReadFile(path)
+		b, err := backend.Read(path)
 		if err != nil {
 			return -1, fmt.Errorf("ev3dev: could not read address %s: %v", path, err)
 		}
@@ -490,7 +608,7 @@ func deviceIDFor(port, driver string, d Device, after int) (int, error) {
 			continue
 		}
 		path = filepath.Join(d.Path(), device.name, driverName)
-		b, err = ioutil.ReadFile(path)
+		b, err = backend.Read(path)
 		if err != nil {
 			return -1, fmt.Errorf("ev3dev: could not read driver name %s: %v", path, err)
 		}
@@ -510,12 +628,7 @@ func deviceIDFor(port, driver string, d Device, after int) (int, error) {
 }
 
 func devicesIn(path string) ([]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	return f.Readdirnames(0)
+	return backend.List(path)
 }
 
 func sortedDevices(names []string, prefix string) ([]idDevice, error) {
@@ -551,7 +664,7 @@ func attributeOf(d Device, attr string) (dev Device, data string, _attr string,
 		return d, "", "", err
 	}
 	path := filepath.Join(d.Path(), d.String(), attr)
-	b, err := ioutil.ReadFile(path)
+	b, err := backend.Read(path)
 	if err != nil {
 		return d, "", "", newAttrOpError(d, attr, string(b), "read", err)
 	}
@@ -559,7 +672,7 @@ func attributeOf(d Device, attr string) (dev Device, data string, _attr string,
 }
 
 func chomp(b []byte) []byte {
-	if b[len(b)-1] == '\n' {
+	if len(b) != 0 && b[len(b)-1] == '\n' {
 		return b[:len(b)-1]
 	}
 	return b
@@ -650,7 +763,7 @@ func ueventFrom(d Device, data, attr string, err error) (map[string]string, erro
 
 func setAttributeOf(d Device, attr, data string) error {
 	path := filepath.Join(d.Path(), d.String(), attr)
-	err := ioutil.WriteFile(path, []byte(data), 0)
+	err := backend.Write(path, []byte(data))
 	if err != nil {
 		return newAttrOpError(d, attr, data, "set", err)
 	}
@@ -0,0 +1,443 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GestureKind is the kind of a button Gesture.
+type GestureKind int
+
+const (
+	// Click is a short press and release
+	// of a single button that is not
+	// followed by a second press within
+	// the double click interval.
+	Click GestureKind = iota + 1
+
+	// DoubleClick is a second press of
+	// a button within the double click
+	// interval after a click.
+	DoubleClick
+
+	// LongPress is a single button held
+	// for the long press duration.
+	LongPress
+
+	// HoldRepeat is sent repeatedly while
+	// a button remains held after a
+	// LongPress.
+	HoldRepeat
+
+	// Chord is a set of buttons pressed
+	// together within the chord window.
+	Chord
+)
+
+var gestureKinds = [...]string{
+	Click:       "click",
+	DoubleClick: "double-click",
+	LongPress:   "long-press",
+	HoldRepeat:  "hold-repeat",
+	Chord:       "chord",
+}
+
+// String satisfies the fmt.Stringer interface.
+func (k GestureKind) String() string {
+	if k <= 0 || int(k) >= len(gestureKinds) {
+		return fmt.Sprintf("GestureKind(%d)", int(k))
+	}
+	return gestureKinds[k]
+}
+
+// Gesture is a button gesture recognised from a sequence of button events.
+type Gesture struct {
+	Kind GestureKind
+
+	// Button is the button of the
+	// gesture, or the set of buttons
+	// of a Chord.
+	Button Button
+
+	// TimeStamp is the time of the
+	// gesture in the time base of the
+	// ButtonEvent time stamps.
+	TimeStamp time.Duration
+
+	// Count is the number of the
+	// repeat for HoldRepeat gestures,
+	// starting from one.
+	Count int
+
+	// Err is any error received
+	// from the button event source.
+	Err error
+}
+
+// GestureTimings holds the timing parameters for gesture recognition.
+type GestureTimings struct {
+	// DoubleClick is the maximum time
+	// between the release of a click and
+	// the following press for a double
+	// click. If DoubleClick is zero, double
+	// clicks are not recognised and clicks
+	// are reported on release.
+	DoubleClick time.Duration
+
+	// LongPress is the time a button must
+	// be held to be a long press.
+	LongPress time.Duration
+
+	// Repeat is the interval between
+	// HoldRepeat gestures. If Repeat is
+	// zero, hold repeats are not sent.
+	Repeat time.Duration
+
+	// ChordWindow is the maximum time
+	// between the first and last press
+	// of the buttons of a chord. If
+	// ChordWindow is zero, chords are not
+	// recognised.
+	ChordWindow time.Duration
+}
+
+// DefaultGestureTimings are the gesture timings used when nil timings are
+// passed to NewGestureRecognizer.
+var DefaultGestureTimings = GestureTimings{
+	DoubleClick: 300 * time.Millisecond,
+	LongPress:   800 * time.Millisecond,
+	Repeat:      200 * time.Millisecond,
+	ChordWindow: 80 * time.Millisecond,
+}
+
+// GestureRecognizer recognises button gestures from a stream of button
+// events, such as the Events of a ButtonWaiter.
+type GestureRecognizer struct {
+	Gestures <-chan Gesture
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// ev_key is the linux input event type for key events.
+const ev_key = 0x01
+
+// Key event values.
+const (
+	keyRelease = 0
+	keyPress   = 1
+)
+
+// NewGestureRecognizer returns a GestureRecognizer reading button events
+// from events. If t is nil, DefaultGestureTimings is used. The Gestures
+// channel is closed when events is closed or the GestureRecognizer is
+// closed. Errors received from events are forwarded as Gestures with a
+// non-nil Err.
+func NewGestureRecognizer(events <-chan ButtonEvent, t *GestureTimings) (*GestureRecognizer, error) {
+	if t == nil {
+		t = &DefaultGestureTimings
+	}
+	if t.DoubleClick < 0 || t.LongPress <= 0 || t.Repeat < 0 || t.ChordWindow < 0 {
+		return nil, fmt.Errorf("ev3dev: invalid gesture timings: %+v", *t)
+	}
+
+	c := make(chan Gesture)
+	g := &GestureRecognizer{Gestures: c, done: make(chan struct{})}
+	s := newGestureState(*t)
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer close(c)
+
+		// The recogniser runs in the time base of the
+		// event time stamps. offset maps the time since
+		// start into that time base, and is updated on
+		// each event.
+		start := time.Now()
+		var offset time.Duration
+		now := func() time.Duration { return time.Since(start) + offset }
+
+		timer := time.NewTimer(0)
+		if !timer.Stop() {
+			<-timer.C
+		}
+		defer timer.Stop()
+
+		for {
+			var wake <-chan time.Time
+			if d, ok := s.next(); ok {
+				timer.Reset(d - now())
+				wake = timer.C
+			}
+
+			var gestures []Gesture
+			select {
+			case <-g.done:
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Err != nil {
+					gestures = []Gesture{{Err: e.Err}}
+					break
+				}
+				if e.Type != ev_key || e.Button == 0 {
+					break
+				}
+				offset = e.TimeStamp - time.Since(start)
+				gestures = s.event(e)
+			case <-wake:
+				wake = nil
+				gestures = s.advance(now())
+			}
+			if wake != nil && !timer.Stop() {
+				<-timer.C
+			}
+
+			for _, ges := range gestures {
+				select {
+				case <-g.done:
+					return
+				case c <- ges:
+				}
+			}
+		}
+	}()
+	return g, nil
+}
+
+// Close stops the GestureRecognizer and closes the Gestures channel. It
+// does not close the source of button events. Close is safe to call more
+// than once and concurrently.
+func (g *GestureRecognizer) Close() error {
+	g.once.Do(func() { close(g.done) })
+	g.wg.Wait()
+	return nil
+}
+
+// gestureState is the gesture recognition state machine. All times are
+// in the time base of the button event time stamps.
+type gestureState struct {
+	timings GestureTimings
+
+	buttons [len(buttons)]buttonState
+
+	// group is the set of buttons pressed
+	// since groupStart while a chord may
+	// still be formed.
+	group      Button
+	groupStart time.Duration
+}
+
+// buttonState is the gesture state of a single button.
+type buttonState struct {
+	down      bool
+	pressedAt time.Duration
+
+	// done is true when the current press
+	// has been reported as part of a chord,
+	// a double click or a long press.
+	done bool
+
+	// held is true after a long press and
+	// repeats is the number of hold repeats
+	// sent.
+	held    bool
+	repeats int
+
+	// clickAt is the time a pending click
+	// will be reported if the button is not
+	// pressed again, and clickedAt is when
+	// the pending click was released.
+	pending   bool
+	clickAt   time.Duration
+	clickedAt time.Duration
+}
+
+func newGestureState(t GestureTimings) *gestureState {
+	return &gestureState{timings: t}
+}
+
+// event handles a button event, returning any gestures resulting from
+// pending timeouts before the event and from the event itself.
+func (s *gestureState) event(e ButtonEvent) []Gesture {
+	gestures := s.advance(e.TimeStamp)
+	for i := range s.buttons {
+		if e.Button&(1<<uint(i)) == 0 {
+			continue
+		}
+		switch e.Value {
+		case keyPress:
+			gestures = append(gestures, s.press(i, e.TimeStamp)...)
+		case keyRelease:
+			gestures = append(gestures, s.release(i, e.TimeStamp)...)
+		}
+	}
+	return gestures
+}
+
+func (s *gestureState) press(i int, ts time.Duration) []Gesture {
+	b := &s.buttons[i]
+	if b.down {
+		return nil
+	}
+	*b = buttonState{
+		down:      true,
+		pressedAt: ts,
+		pending:   b.pending,
+		clickAt:   b.clickAt,
+		clickedAt: b.clickedAt,
+	}
+
+	if s.timings.ChordWindow > 0 {
+		if s.group == 0 {
+			s.groupStart = ts
+		}
+		s.group |= 1 << uint(i)
+	}
+
+	if b.pending {
+		b.pending = false
+		b.done = true
+		return []Gesture{{Kind: DoubleClick, Button: 1 << uint(i), TimeStamp: ts}}
+	}
+	return nil
+}
+
+func (s *gestureState) release(i int, ts time.Duration) []Gesture {
+	var gestures []Gesture
+	if s.group&(1<<uint(i)) != 0 {
+		gestures = s.closeGroup(ts)
+	}
+
+	b := &s.buttons[i]
+	if !b.down {
+		return gestures
+	}
+	b.down = false
+	if b.done || b.held {
+		return gestures
+	}
+	if s.timings.DoubleClick == 0 {
+		return append(gestures, Gesture{Kind: Click, Button: 1 << uint(i), TimeStamp: ts})
+	}
+	b.pending = true
+	b.clickedAt = ts
+	b.clickAt = ts + s.timings.DoubleClick
+	return gestures
+}
+
+// closeGroup ends the current chord group at time ts, reporting
+// a Chord if more than one button was pressed.
+func (s *gestureState) closeGroup(ts time.Duration) []Gesture {
+	group := s.group
+	s.group = 0
+	if group&(group-1) == 0 {
+		// Zero or one button.
+		return nil
+	}
+	for i := range s.buttons {
+		if group&(1<<uint(i)) != 0 {
+			s.buttons[i].done = true
+			s.buttons[i].pending = false
+		}
+	}
+	return []Gesture{{Kind: Chord, Button: group, TimeStamp: ts}}
+}
+
+// advance reports all gestures due at or before now in time order.
+func (s *gestureState) advance(now time.Duration) []Gesture {
+	var gestures []Gesture
+	if s.group != 0 {
+		end := s.groupStart + s.timings.ChordWindow
+		if end > now {
+			// Gestures of buttons in a forming
+			// chord are deferred until the chord
+			// window closes.
+			return nil
+		}
+		gestures = s.closeGroup(end)
+	}
+
+	for i := range s.buttons {
+		b := &s.buttons[i]
+		if b.pending && b.clickAt <= now {
+			b.pending = false
+			gestures = append(gestures, Gesture{Kind: Click, Button: 1 << uint(i), TimeStamp: b.clickedAt})
+		}
+		if !b.down || b.done {
+			continue
+		}
+		if !b.held {
+			at := b.pressedAt + s.timings.LongPress
+			if at > now {
+				continue
+			}
+			b.held = true
+			gestures = append(gestures, Gesture{Kind: LongPress, Button: 1 << uint(i), TimeStamp: at})
+		}
+		if s.timings.Repeat == 0 {
+			continue
+		}
+		for {
+			at := b.pressedAt + s.timings.LongPress + time.Duration(b.repeats+1)*s.timings.Repeat
+			if at > now {
+				break
+			}
+			b.repeats++
+			gestures = append(gestures, Gesture{Kind: HoldRepeat, Button: 1 << uint(i), TimeStamp: at, Count: b.repeats})
+		}
+	}
+	sort.Stable(byTimeStamp(gestures))
+	return gestures
+}
+
+// next returns the time of the next pending timeout, and
+// whether there is one.
+func (s *gestureState) next() (time.Duration, bool) {
+	var (
+		next time.Duration
+		ok   bool
+	)
+	update := func(t time.Duration) {
+		if !ok || t < next {
+			next = t
+			ok = true
+		}
+	}
+	if s.group != 0 {
+		// Nothing else can fire while
+		// a chord is forming.
+		return s.groupStart + s.timings.ChordWindow, true
+	}
+	for _, b := range s.buttons {
+		if b.pending {
+			update(b.clickAt)
+		}
+		if !b.down || b.done {
+			continue
+		}
+		switch {
+		case !b.held:
+			update(b.pressedAt + s.timings.LongPress)
+		case s.timings.Repeat != 0:
+			update(b.pressedAt + s.timings.LongPress + time.Duration(b.repeats+1)*s.timings.Repeat)
+		}
+	}
+	return next, ok
+}
+
+type byTimeStamp []Gesture
+
+func (g byTimeStamp) Len() int           { return len(g) }
+func (g byTimeStamp) Less(i, j int) bool { return g[i].TimeStamp < g[j].TimeStamp }
+func (g byTimeStamp) Swap(i, j int)      { g[i], g[j] = g[j], g[i] }
@@ -0,0 +1,199 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const ms = time.Millisecond
+
+var testTimings = GestureTimings{
+	DoubleClick: 100 * ms,
+	LongPress:   500 * ms,
+	Repeat:      100 * ms,
+	ChordWindow: 50 * ms,
+}
+
+func pressEvent(b Button, t time.Duration) ButtonEvent {
+	return ButtonEvent{Button: b, TimeStamp: t, Type: ev_key, Value: keyPress}
+}
+
+func releaseEvent(b Button, t time.Duration) ButtonEvent {
+	return ButtonEvent{Button: b, TimeStamp: t, Type: ev_key, Value: keyRelease}
+}
+
+var gestureTests = []struct {
+	name    string
+	timings GestureTimings
+	events  []ButtonEvent
+	end     time.Duration
+	want    []Gesture
+}{
+	{
+		name:    "click",
+		timings: testTimings,
+		events:  []ButtonEvent{pressEvent(Left, 1000*ms), releaseEvent(Left, 1100*ms)},
+		end:     2000 * ms,
+		want:    []Gesture{{Kind: Click, Button: Left, TimeStamp: 1100 * ms}},
+	},
+	{
+		name:    "click without double click",
+		timings: GestureTimings{LongPress: 500 * ms},
+		events:  []ButtonEvent{pressEvent(Left, 1000*ms), releaseEvent(Left, 1100*ms), pressEvent(Left, 1150*ms), releaseEvent(Left, 1200*ms)},
+		end:     2000 * ms,
+		want: []Gesture{
+			{Kind: Click, Button: Left, TimeStamp: 1100 * ms},
+			{Kind: Click, Button: Left, TimeStamp: 1200 * ms},
+		},
+	},
+	{
+		name:    "double click",
+		timings: testTimings,
+		events:  []ButtonEvent{pressEvent(Up, 1000*ms), releaseEvent(Up, 1050*ms), pressEvent(Up, 1120*ms), releaseEvent(Up, 1160*ms)},
+		end:     2000 * ms,
+		want:    []Gesture{{Kind: DoubleClick, Button: Up, TimeStamp: 1120 * ms}},
+	},
+	{
+		name:    "two clicks",
+		timings: testTimings,
+		events:  []ButtonEvent{pressEvent(Up, 1000*ms), releaseEvent(Up, 1050*ms), pressEvent(Up, 1150*ms), releaseEvent(Up, 1200*ms)},
+		end:     2000 * ms,
+		want: []Gesture{
+			{Kind: Click, Button: Up, TimeStamp: 1050 * ms},
+			{Kind: Click, Button: Up, TimeStamp: 1200 * ms},
+		},
+	},
+	{
+		name:    "long press",
+		timings: GestureTimings{LongPress: 500 * ms, DoubleClick: 100 * ms},
+		events:  []ButtonEvent{pressEvent(Middle, 1000*ms), releaseEvent(Middle, 1800*ms)},
+		end:     2000 * ms,
+		want:    []Gesture{{Kind: LongPress, Button: Middle, TimeStamp: 1500 * ms}},
+	},
+	{
+		name:    "hold repeat",
+		timings: testTimings,
+		events:  []ButtonEvent{pressEvent(Down, 1000*ms), releaseEvent(Down, 1750*ms)},
+		end:     2000 * ms,
+		want: []Gesture{
+			{Kind: LongPress, Button: Down, TimeStamp: 1500 * ms},
+			{Kind: HoldRepeat, Button: Down, TimeStamp: 1600 * ms, Count: 1},
+			{Kind: HoldRepeat, Button: Down, TimeStamp: 1700 * ms, Count: 2},
+		},
+	},
+	{
+		name:    "chord",
+		timings: testTimings,
+		events: []ButtonEvent{
+			pressEvent(Left, 1000*ms), pressEvent(Right, 1030*ms),
+			releaseEvent(Left, 1200*ms), releaseEvent(Right, 1210*ms),
+		},
+		end:  2000 * ms,
+		want: []Gesture{{Kind: Chord, Button: Left | Right, TimeStamp: 1050 * ms}},
+	},
+	{
+		name:    "chord held",
+		timings: testTimings,
+		events: []ButtonEvent{
+			pressEvent(Left, 1000*ms), pressEvent(Right, 1030*ms),
+			releaseEvent(Left, 1800*ms), releaseEvent(Right, 1810*ms),
+		},
+		end:  2000 * ms,
+		want: []Gesture{{Kind: Chord, Button: Left | Right, TimeStamp: 1050 * ms}},
+	},
+	{
+		name:    "chord released early",
+		timings: testTimings,
+		events: []ButtonEvent{
+			pressEvent(Back, 1000*ms), pressEvent(Middle, 1010*ms),
+			releaseEvent(Back, 1020*ms), releaseEvent(Middle, 1030*ms),
+		},
+		end:  2000 * ms,
+		want: []Gesture{{Kind: Chord, Button: Back | Middle, TimeStamp: 1020 * ms}},
+	},
+	{
+		name:    "presses outside chord window",
+		timings: testTimings,
+		events: []ButtonEvent{
+			pressEvent(Left, 1000*ms), pressEvent(Right, 1100*ms),
+			releaseEvent(Right, 1150*ms), releaseEvent(Left, 1200*ms),
+		},
+		end: 2000 * ms,
+		want: []Gesture{
+			{Kind: Click, Button: Right, TimeStamp: 1150 * ms},
+			{Kind: Click, Button: Left, TimeStamp: 1200 * ms},
+		},
+	},
+}
+
+func TestGestureState(t *testing.T) {
+	for _, test := range gestureTests {
+		s := newGestureState(test.timings)
+		var got []Gesture
+		for _, e := range test.events {
+			got = append(got, s.event(e)...)
+		}
+		for {
+			next, ok := s.next()
+			if !ok || next > test.end {
+				break
+			}
+			got = append(got, s.advance(next)...)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected gestures for %s:\ngot: %+v\nwant:%+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGestureRecognizer(t *testing.T) {
+	events := make(chan ButtonEvent)
+	g, err := NewGestureRecognizer(events, &GestureTimings{LongPress: 20 * ms})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer g.Close()
+
+	base := 1000 * time.Second
+	events <- ButtonEvent{Type: 0, TimeStamp: base}
+	events <- pressEvent(Left, base)
+	select {
+	case ges := <-g.Gestures:
+		if ges.Kind != LongPress || ges.Button != Left || ges.TimeStamp != base+20*ms {
+			t.Errorf("unexpected gesture: got:%+v want long press of Left", ges)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for long press")
+	}
+	events <- releaseEvent(Left, base+100*ms)
+
+	readErr := errors.New("read error")
+	events <- ButtonEvent{Err: readErr}
+	ges := <-g.Gestures
+	if ges.Err != readErr {
+		t.Errorf("unexpected error gesture: got:%v want:%v", ges.Err, readErr)
+	}
+
+	close(events)
+	select {
+	case _, ok := <-g.Gestures:
+		if ok {
+			t.Error("unexpected gesture after events closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for gestures to close")
+	}
+}
+
+func TestGestureRecognizerInvalid(t *testing.T) {
+	_, err := NewGestureRecognizer(nil, &GestureTimings{})
+	if err == nil {
+		t.Error("expected error for zero long press duration")
+	}
+}
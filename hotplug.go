@@ -0,0 +1,396 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DeviceAction is a device hot-plug action.
+type DeviceAction string
+
+// Device hot-plug actions.
+const (
+	DeviceAdded   DeviceAction = "add"
+	DeviceRemoved DeviceAction = "remove"
+	DeviceChanged DeviceAction = "change"
+)
+
+// DeviceEvent is a device hot-plug event.
+type DeviceEvent struct {
+	// Action is the hot-plug action.
+	Action DeviceAction
+
+	// Class is the device class name,
+	// for example "tacho-motor", and
+	// Name is the device name within
+	// the class, for example "motor0".
+	Class string
+	Name  string
+
+	// Address and Driver are the port
+	// address and driver name of the
+	// device. They may be empty if the
+	// device was removed before they
+	// could be determined.
+	Address string
+	Driver  string
+}
+
+// DeviceWatcher reports devices being added to, removed from and changed
+// in a set of device classes.
+//
+// DeviceWatcher listens for kernel kobject uevents on a netlink socket
+// when one is available, and otherwise polls the class directories.
+type DeviceWatcher struct {
+	root    string
+	classes map[string]string
+
+	// known holds the address and driver
+	// of each known device, keyed by
+	// class path and device name.
+	known map[string]map[string]deviceInfo
+
+	events chan DeviceEvent
+
+	mu  sync.Mutex
+	err error
+
+	// sock is the netlink socket, or -1 if
+	// the class directories are polled.
+	sock int
+
+	// wake is the read end of a pipe
+	// that is closed by Close to
+	// interrupt a netlink read.
+	wake *os.File
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+type deviceInfo struct {
+	address string
+	driver  string
+}
+
+// NewDeviceWatcher returns a running DeviceWatcher for the given class
+// paths. If no class path is given, LegoPortPath, SensorPath, TachoMotorPath,
+// ServoMotorPath and DCMotorPath are watched. The period is used as the
+// polling interval if the netlink uevent socket is not available.
+func NewDeviceWatcher(period time.Duration, classPaths ...string) (*DeviceWatcher, error) {
+	return newDeviceWatcher(prefix, prefix == "" && isSysfs(), period, classPaths...)
+}
+
+func newDeviceWatcher(root string, useNetlink bool, period time.Duration, classPaths ...string) (*DeviceWatcher, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("ev3dev: invalid device watcher period: %v", period)
+	}
+	if len(classPaths) == 0 {
+		classPaths = []string{LegoPortPath, SensorPath, TachoMotorPath, ServoMotorPath, DCMotorPath}
+	}
+	w := &DeviceWatcher{
+		root:    root,
+		classes: make(map[string]string),
+		known:   make(map[string]map[string]deviceInfo),
+		events:  make(chan DeviceEvent, 16),
+		sock:    -1,
+		done:    make(chan struct{}),
+	}
+	for _, p := range classPaths {
+		w.classes[filepath.Base(p)] = p
+		w.known[p] = w.scan(p)
+	}
+
+	if useNetlink {
+		err := w.openNetlink()
+		if err != nil {
+			w.sock = -1
+		}
+	}
+
+	w.wg.Add(1)
+	if w.sock < 0 {
+		go w.poll(period)
+	} else {
+		go w.listen()
+	}
+	return w, nil
+}
+
+// openNetlink opens the kobject uevent netlink socket and the wakeup
+// pipe used to interrupt reads from it.
+func (w *DeviceWatcher) openNetlink() error {
+	sock, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return err
+	}
+	err = unix.Bind(sock, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1, Pid: 0})
+	if err != nil {
+		unix.Close(sock)
+		return err
+	}
+	r, wake, err := os.Pipe()
+	if err != nil {
+		unix.Close(sock)
+		return err
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		<-w.done
+		wake.Close()
+	}()
+	w.sock = sock
+	w.wake = r
+	return nil
+}
+
+// Events returns the channel on which device events are reported. The
+// channel is closed when the DeviceWatcher is closed or fails.
+func (w *DeviceWatcher) Events() <-chan DeviceEvent {
+	return w.events
+}
+
+// Err returns the error that caused the DeviceWatcher to stop, if any.
+func (w *DeviceWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops the DeviceWatcher. Close is safe to call more than once
+// and concurrently.
+func (w *DeviceWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return nil
+}
+
+func (w *DeviceWatcher) fail(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
+
+// send sends e on the events channel, returning false if the
+// DeviceWatcher is closed.
+func (w *DeviceWatcher) send(e DeviceEvent) bool {
+	select {
+	case w.events <- e:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// listen reads uevents from the netlink socket until the DeviceWatcher
+// is closed.
+func (w *DeviceWatcher) listen() {
+	defer w.wg.Done()
+	defer close(w.events)
+	defer unix.Close(w.sock)
+	defer w.wake.Close()
+
+	fds := []unix.PollFd{
+		{Fd: int32(w.sock), Events: unix.POLLIN},
+		{Fd: int32(w.wake.Fd()), Events: unix.POLLIN},
+	}
+	buf := make([]byte, 8192)
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			w.fail(fmt.Errorf("ev3dev: failed to poll uevent socket: %v", err))
+			return
+		}
+		if fds[1].Revents != 0 {
+			return
+		}
+		n, _, err := unix.Recvfrom(w.sock, buf, 0)
+		if err == unix.EINTR || err == unix.EAGAIN || err == unix.ENOBUFS {
+			continue
+		}
+		if err != nil {
+			w.fail(fmt.Errorf("ev3dev: failed to read uevent: %v", err))
+			return
+		}
+		e, ok := w.eventFrom(parseUevent(buf[:n]))
+		if !ok {
+			continue
+		}
+		if !w.send(e) {
+			return
+		}
+	}
+}
+
+// eventFrom returns the DeviceEvent for the given uevent environment and
+// updates the known devices. It returns false if the uevent is not for a
+// watched class or is not an add, remove or change action.
+func (w *DeviceWatcher) eventFrom(env map[string]string) (DeviceEvent, bool) {
+	action := DeviceAction(env["ACTION"])
+	switch action {
+	case DeviceAdded, DeviceRemoved, DeviceChanged:
+	default:
+		return DeviceEvent{}, false
+	}
+	class := env["SUBSYSTEM"]
+	path, ok := w.classes[class]
+	if !ok {
+		return DeviceEvent{}, false
+	}
+	name := filepath.Base(env["DEVPATH"])
+	if name == "." || name == "/" {
+		return DeviceEvent{}, false
+	}
+
+	info := deviceInfo{address: env["LEGO_ADDRESS"], driver: env["LEGO_DRIVER_NAME"]}
+	if action == DeviceRemoved {
+		if known, ok := w.known[path][name]; ok {
+			if info.address == "" {
+				info.address = known.address
+			}
+			if info.driver == "" {
+				info.driver = known.driver
+			}
+		}
+		delete(w.known[path], name)
+	} else {
+		if info.address == "" || info.driver == "" {
+			read := w.info(path, name)
+			if info.address == "" {
+				info.address = read.address
+			}
+			if info.driver == "" {
+				info.driver = read.driver
+			}
+		}
+		w.known[path][name] = info
+	}
+	return DeviceEvent{Action: action, Class: class, Name: name, Address: info.address, Driver: info.driver}, true
+}
+
+// parseUevent returns the environment of a kernel uevent message. The
+// message is a header of the form action@devpath followed by NUL-terminated
+// KEY=VALUE pairs.
+func parseUevent(b []byte) map[string]string {
+	env := make(map[string]string)
+	for i, f := range bytes.Split(b, []byte{0}) {
+		if i == 0 && bytes.IndexByte(f, '@') >= 0 {
+			// Skip the header; the
+			// same information is
+			// in ACTION and DEVPATH.
+			continue
+		}
+		kv := strings.SplitN(string(f), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		env[kv[0]] = kv[1]
+	}
+	return env
+}
+
+// poll polls the class directories every period until the DeviceWatcher
+// is closed.
+func (w *DeviceWatcher) poll(period time.Duration) {
+	defer w.wg.Done()
+	defer close(w.events)
+
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+		}
+		for class, path := range w.classes {
+			for _, e := range w.diff(class, path) {
+				if !w.send(e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// diff returns the events for the changes in the devices of the class since
+// the last scan and updates the known devices.
+func (w *DeviceWatcher) diff(class, path string) []DeviceEvent {
+	var events []DeviceEvent
+	last := w.known[path]
+	curr := w.scan(path)
+	for _, name := range sortedNames(last) {
+		if _, ok := curr[name]; !ok {
+			info := last[name]
+			events = append(events, DeviceEvent{Action: DeviceRemoved, Class: class, Name: name, Address: info.address, Driver: info.driver})
+		}
+	}
+	for _, name := range sortedNames(curr) {
+		info := curr[name]
+		old, ok := last[name]
+		switch {
+		case !ok:
+			events = append(events, DeviceEvent{Action: DeviceAdded, Class: class, Name: name, Address: info.address, Driver: info.driver})
+		case old != info:
+			events = append(events, DeviceEvent{Action: DeviceChanged, Class: class, Name: name, Address: info.address, Driver: info.driver})
+		}
+	}
+	w.known[path] = curr
+	return events
+}
+
+// scan returns the devices currently present in the class path.
+func (w *DeviceWatcher) scan(path string) map[string]deviceInfo {
+	devices := make(map[string]deviceInfo)
+	names, err := devicesIn(filepath.Join(w.root, path))
+	if err != nil {
+		// A missing class directory
+		// has no devices.
+		return devices
+	}
+	for _, n := range names {
+		devices[n] = w.info(path, n)
+	}
+	return devices
+}
+
+// info returns the address and driver name of the named device in the
+// class path. Attributes that cannot be read are left empty.
+func (w *DeviceWatcher) info(path, name string) deviceInfo {
+	var info deviceInfo
+	b, err := backend.Read(filepath.Join(w.root, path, name, address))
+	if err == nil && len(b) != 0 {
+		info.address = string(chomp(b))
+	}
+	b, err = backend.Read(filepath.Join(w.root, path, name, driverName))
+	if err == nil && len(b) != 0 {
+		info.driver = string(chomp(b))
+	}
+	return info
+}
+
+func sortedNames(devices map[string]deviceInfo) []string {
+	names := make([]string, 0, len(devices))
+	for n := range devices {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,135 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseUevent(t *testing.T) {
+	msg := []byte("add@/devices/platform/legoev3-ports/outA/outA:lego-ev3-l-motor/tacho-motor/motor0\x00" +
+		"ACTION=add\x00" +
+		"DEVPATH=/devices/platform/legoev3-ports/outA/outA:lego-ev3-l-motor/tacho-motor/motor0\x00" +
+		"SUBSYSTEM=tacho-motor\x00" +
+		"LEGO_DRIVER_NAME=lego-ev3-l-motor\x00" +
+		"LEGO_ADDRESS=outA\x00" +
+		"SEQNUM=1234\x00")
+	want := map[string]string{
+		"ACTION":           "add",
+		"DEVPATH":          "/devices/platform/legoev3-ports/outA/outA:lego-ev3-l-motor/tacho-motor/motor0",
+		"SUBSYSTEM":        "tacho-motor",
+		"LEGO_DRIVER_NAME": "lego-ev3-l-motor",
+		"LEGO_ADDRESS":     "outA",
+		"SEQNUM":           "1234",
+	}
+	got := parseUevent(msg)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected uevent environment:\ngot: %v\nwant:%v", got, want)
+	}
+
+	w := &DeviceWatcher{
+		classes: map[string]string{"tacho-motor": TachoMotorPath},
+		known:   map[string]map[string]deviceInfo{TachoMotorPath: {}},
+	}
+	e, ok := w.eventFrom(got)
+	wantEvent := DeviceEvent{Action: DeviceAdded, Class: "tacho-motor", Name: "motor0", Address: "outA", Driver: "lego-ev3-l-motor"}
+	if !ok || e != wantEvent {
+		t.Errorf("unexpected add event: got:%+v want:%+v", e, wantEvent)
+	}
+	e, ok = w.eventFrom(map[string]string{
+		"ACTION":    "remove",
+		"DEVPATH":   got["DEVPATH"],
+		"SUBSYSTEM": "tacho-motor",
+	})
+	wantEvent.Action = DeviceRemoved
+	if !ok || e != wantEvent {
+		t.Errorf("unexpected remove event: got:%+v want:%+v", e, wantEvent)
+	}
+	_, ok = w.eventFrom(map[string]string{
+		"ACTION":    "add",
+		"DEVPATH":   "/devices/platform/sound/input/input1",
+		"SUBSYSTEM": "input",
+	})
+	if ok {
+		t.Error("unexpected event for unwatched class")
+	}
+}
+
+func TestDeviceWatcherPoll(t *testing.T) {
+	root, err := ioutil.TempDir("", "ev3dev-hotplug")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	addDevice := func(name, addr, driver string) {
+		dir := filepath.Join(root, SensorPath, name)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			t.Fatalf("failed to create device directory: %v", err)
+		}
+		for attr, val := range map[string]string{address: addr, driverName: driver} {
+			err = ioutil.WriteFile(filepath.Join(dir, attr), []byte(val+"\n"), 0644)
+			if err != nil {
+				t.Fatalf("failed to write %s: %v", attr, err)
+			}
+		}
+	}
+	addDevice("sensor0", "in1", "lego-ev3-touch")
+
+	w, err := newDeviceWatcher(root, false, time.Millisecond, SensorPath)
+	if err != nil {
+		t.Fatalf("unexpected error creating watcher: %v", err)
+	}
+	defer w.Close()
+
+	next := func() DeviceEvent {
+		select {
+		case e := <-w.Events():
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		panic("unreachable")
+	}
+
+	addDevice("sensor1", "in2", "lego-ev3-color")
+	want := DeviceEvent{Action: DeviceAdded, Class: "lego-sensor", Name: "sensor1", Address: "in2", Driver: "lego-ev3-color"}
+	if got := next(); got != want {
+		t.Errorf("unexpected event: got:%+v want:%+v", got, want)
+	}
+
+	err = os.RemoveAll(filepath.Join(root, SensorPath, "sensor0"))
+	if err != nil {
+		t.Fatalf("failed to remove device: %v", err)
+	}
+	want = DeviceEvent{Action: DeviceRemoved, Class: "lego-sensor", Name: "sensor0", Address: "in1", Driver: "lego-ev3-touch"}
+	if got := next(); got != want {
+		t.Errorf("unexpected event: got:%+v want:%+v", got, want)
+	}
+
+	// Concurrent calls to Close must not panic.
+	closed := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() { closed <- w.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-closed:
+			if err != nil {
+				t.Errorf("unexpected error closing watcher: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for close")
+		}
+	}
+	for range w.Events() {
+	}
+}
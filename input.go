@@ -0,0 +1,177 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InputPath is the directory holding linux input event devices.
+const InputPath = "/dev/input"
+
+// InputDevice describes a linux input event device.
+type InputDevice struct {
+	// Path is the path of the
+	// event device.
+	Path string
+
+	// Name is the name reported
+	// by the device driver.
+	Name string
+
+	keys []byte
+}
+
+// HasKeys returns whether the device reports all of the given linux key
+// codes.
+func (d InputDevice) HasKeys(codes ...uint) bool {
+	for _, c := range codes {
+		if c >= uint(len(d.keys))*8 || !isSet(c, d.keys) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsButtons returns whether the device reports all of the key codes of
+// the EV3 buttons.
+func (d InputDevice) IsButtons() bool {
+	return d.HasKeys(buttons[:]...)
+}
+
+// ev3ButtonNames are the names reported by the EV3 brick button driver.
+var ev3ButtonNames = map[string]bool{
+	"EV3 Brick Buttons": true,
+	"gpio-keys":         true,
+}
+
+// ButtonInput returns a ButtonInput for the device. The KeyMap of the
+// ButtonInput is EV3KeyMap if the device is the EV3 brick buttons, and
+// KeyboardKeyMap otherwise.
+func (d InputDevice) ButtonInput() ButtonInput {
+	if ev3ButtonNames[d.Name] {
+		return ButtonInput{Path: d.Path, Keys: &EV3KeyMap}
+	}
+	return ButtonInput{Path: d.Path, Keys: &KeyboardKeyMap}
+}
+
+// InputDevices returns the input event devices in InputPath sorted by
+// event number. Devices that cannot be opened or queried are omitted.
+func InputDevices() ([]InputDevice, error) {
+	return inputDevicesIn(InputPath, probeInput)
+}
+
+// ButtonDevice returns the path of the input event device providing the
+// EV3 buttons. The EV3 brick button device is preferred, otherwise the
+// first device that reports all of the EV3 button key codes, such as a
+// keyboard, is returned.
+func ButtonDevice() (string, error) {
+	in, err := buttonInputIn(InputPath, probeInput)
+	return in.Path, err
+}
+
+// buttonInputIn returns the ButtonInput for the button device in dir.
+func buttonInputIn(dir string, probe func(string) (InputDevice, error)) (ButtonInput, error) {
+	devs, err := inputDevicesIn(dir, probe)
+	if err != nil {
+		return ButtonInput{}, err
+	}
+	var (
+		found InputDevice
+		ok    bool
+	)
+	for _, d := range devs {
+		if !d.IsButtons() {
+			continue
+		}
+		if ev3ButtonNames[d.Name] {
+			return d.ButtonInput(), nil
+		}
+		if !ok {
+			found = d
+			ok = true
+		}
+	}
+	if !ok {
+		return ButtonInput{}, fmt.Errorf("ev3dev: no button input device found in %s", dir)
+	}
+	return found.ButtonInput(), nil
+}
+
+func inputDevicesIn(dir string, probe func(string) (InputDevice, error)) ([]InputDevice, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ev3dev: failed to open input device directory: %v", err)
+	}
+	names, err := f.Readdirnames(0)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("ev3dev: failed to read input device directory: %v", err)
+	}
+
+	var events []int
+	for _, n := range names {
+		if !strings.HasPrefix(n, "event") {
+			continue
+		}
+		id, err := strconv.Atoi(n[len("event"):])
+		if err != nil {
+			continue
+		}
+		events = append(events, id)
+	}
+	sort.Ints(events)
+
+	var devs []InputDevice
+	for _, id := range events {
+		d, err := probe(filepath.Join(dir, fmt.Sprint("event", id)))
+		if err != nil {
+			continue
+		}
+		devs = append(devs, d)
+	}
+	return devs, nil
+}
+
+// probeInput returns the name and key capabilities of the
+// input event device at path.
+func probeInput(path string) (InputDevice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return InputDevice{}, err
+	}
+	defer f.Close()
+
+	name := make([]byte, 256)
+	err = ioctl(f.Fd(), eviocgname(name), reflect.ValueOf(name).Index(0).Addr().Pointer())
+	if err != nil {
+		return InputDevice{}, err
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	keys := make([]byte, keyBufLen)
+	err = ioctl(f.Fd(), eviocgbit(ev_key, keys), reflect.ValueOf(keys).Index(0).Addr().Pointer())
+	if err != nil {
+		return InputDevice{}, err
+	}
+	return InputDevice{Path: path, Name: string(name), keys: keys}, nil
+}
+
+func eviocgname(buf []byte) uintptr {
+	return _ioc_read<<_ioc_dirshift | uintptr(len(buf))<<_ioc_sizeshift | 'E'<<_ioc_typeshift | 0x06<<_ioc_nrshift
+}
+
+func eviocgbit(ev uintptr, buf []byte) uintptr {
+	return _ioc_read<<_ioc_dirshift | uintptr(len(buf))<<_ioc_sizeshift | 'E'<<_ioc_typeshift | (0x20+ev)<<_ioc_nrshift
+}
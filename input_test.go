@@ -0,0 +1,100 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func keyBits(codes ...uint) []byte {
+	b := make([]byte, keyBufLen)
+	for _, c := range codes {
+		b[c>>3] |= 1 << (c & 7)
+	}
+	return b
+}
+
+func TestButtonDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ev3dev-input")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	devices := map[string]InputDevice{
+		"event0":  {Name: "gpio-keys", keys: keyBits(key_up, key_down)},
+		"event2":  {Name: "broken"},
+		"event10": {Name: "USB Keyboard", keys: keyBits(buttons[:]...)},
+		"event3":  {Name: "EV3 Brick Buttons", keys: keyBits(buttons[:]...)},
+		"mouse0":  {Name: "mouse"},
+	}
+	for name := range devices {
+		err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+		if err != nil {
+			t.Fatalf("failed to create device file: %v", err)
+		}
+	}
+	probe := func(path string) (InputDevice, error) {
+		d, ok := devices[filepath.Base(path)]
+		if !ok || d.Name == "broken" {
+			return InputDevice{}, errors.New("probe failed")
+		}
+		d.Path = path
+		return d, nil
+	}
+
+	devs, err := inputDevicesIn(dir, probe)
+	if err != nil {
+		t.Fatalf("unexpected error listing input devices: %v", err)
+	}
+	var names []string
+	for _, d := range devs {
+		names = append(names, d.Name)
+	}
+	want := []string{"gpio-keys", "EV3 Brick Buttons", "USB Keyboard"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected input devices: got:%q want:%q", names, want)
+	}
+	if devs[0].IsButtons() || !devs[0].HasKeys(key_up) || devs[0].HasKeys(key_max+1) {
+		t.Errorf("unexpected key capabilities for %s", devs[0].Name)
+	}
+
+	in, err := buttonInputIn(dir, probe)
+	if err != nil || in.Path != filepath.Join(dir, "event3") || in.Keys != &EV3KeyMap {
+		t.Errorf("unexpected button device: got:%+v want:%q with EV3KeyMap (err=%v)", in, filepath.Join(dir, "event3"), err)
+	}
+
+	// A keyboard is used in the absence of
+	// the EV3 buttons, and is not inverted.
+	devices["event1"] = InputDevice{Name: "AT Keyboard", keys: keyBits(buttons[:]...)}
+	err = ioutil.WriteFile(filepath.Join(dir, "event1"), nil, 0644)
+	if err != nil {
+		t.Fatalf("failed to create device file: %v", err)
+	}
+	in, err = buttonInputIn(dir, probe)
+	if err != nil || in.Path != filepath.Join(dir, "event3") {
+		t.Errorf("unexpected button device with keyboard: got:%q want:%q (err=%v)", in.Path, filepath.Join(dir, "event3"), err)
+	}
+	delete(devices, "event3")
+	in, err = buttonInputIn(dir, probe)
+	if err != nil || in.Path != filepath.Join(dir, "event1") || in.Keys != &KeyboardKeyMap {
+		t.Errorf("unexpected button device without EV3 buttons: got:%+v want:%q with KeyboardKeyMap (err=%v)", in, filepath.Join(dir, "event1"), err)
+	}
+	if got := getButton(make([]byte, keyBufLen), in.Keys); got != 0 {
+		t.Errorf("unexpected buttons for idle keyboard: got:%v want:0", got)
+	}
+
+	delete(devices, "event1")
+	delete(devices, "event10")
+	_, err = buttonInputIn(dir, probe)
+	if err == nil {
+		t.Error("expected error with no button device")
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package round provides the integer rounding shared by the ev3dev packages.
+package round
+
+import "math"
+
+// Int returns f rounded to the nearest integer, with halves rounded
+// away from zero.
+func Int(f float64) int {
+	if f < 0 {
+		return -int(math.Floor(-f + 0.5))
+	}
+	return int(math.Floor(f + 0.5))
+}
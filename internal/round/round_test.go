@@ -0,0 +1,32 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package round
+
+import "testing"
+
+var intTests = []struct {
+	f    float64
+	want int
+}{
+	{f: 0, want: 0},
+	{f: 0.4, want: 0},
+	{f: 0.49, want: 0},
+	{f: 0.5, want: 1},
+	{f: -0.4, want: 0},
+	{f: -0.49, want: 0},
+	{f: -0.5, want: -1},
+	{f: 359.7, want: 360},
+	{f: 719.6, want: 720},
+	{f: -719.6, want: -720},
+}
+
+func TestInt(t *testing.T) {
+	for _, test := range intTests {
+		got := Int(test.f)
+		if got != test.want {
+			t.Errorf("unexpected rounding of %v: got:%d want:%d", test.f, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DeviceInfo is a description of an attached device.
+type DeviceInfo struct {
+	// Class is the device class name,
+	// for example "tacho-motor", and
+	// Name is the device name within
+	// the class, for example "motor0".
+	Class string `json:"class"`
+	Name  string `json:"name"`
+
+	// Address and Driver are the port
+	// address and driver name of the
+	// device.
+	Address string `json:"address,omitempty"`
+	Driver  string `json:"driver,omitempty"`
+
+	// Mode is the current mode of the
+	// device and Modes and Commands are
+	// the available modes and commands.
+	Mode     string   `json:"mode,omitempty"`
+	Modes    []string `json:"modes,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+}
+
+// key returns the identity of the device used for comparison. Device
+// names are assigned by the kernel in order of discovery, so devices
+// with an address are identified by their class and address.
+func (d DeviceInfo) key() string {
+	if d.Address != "" {
+		return d.Class + "@" + d.Address
+	}
+	return d.Class + "/" + d.Name
+}
+
+// String satisfies the fmt.Stringer interface.
+func (d DeviceInfo) String() string {
+	if d.Address != "" {
+		return fmt.Sprintf("%s %s on %s (%s)", d.Class, d.Name, d.Address, d.Driver)
+	}
+	return fmt.Sprintf("%s %s", d.Class, d.Name)
+}
+
+// DeviceInventory is a snapshot of the attached devices, sorted by class
+// and then by address or name.
+type DeviceInventory []DeviceInfo
+
+func (inv DeviceInventory) Len() int           { return len(inv) }
+func (inv DeviceInventory) Less(i, j int) bool { return inv[i].key() < inv[j].key() }
+func (inv DeviceInventory) Swap(i, j int)      { inv[i], inv[j] = inv[j], inv[i] }
+
+// Inventory returns a snapshot of the devices attached in the lego-port,
+// lego-sensor, tacho-motor, servo-motor, dc-motor, leds and power_supply
+// classes.
+func Inventory() (DeviceInventory, error) {
+	return inventoryOf(prefix)
+}
+
+func inventoryOf(root string) (DeviceInventory, error) {
+	var inv DeviceInventory
+	for _, path := range []string{
+		LegoPortPath,
+		SensorPath,
+		TachoMotorPath,
+		ServoMotorPath,
+		DCMotorPath,
+		LEDPath,
+		PowerSupplyPath,
+	} {
+		names, err := devicesIn(filepath.Join(root, path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ev3dev: could not get devices for %s: %v", path, err)
+		}
+		class := filepath.Base(path)
+		for _, n := range names {
+			d := DeviceInfo{Class: class, Name: n}
+			for _, attr := range []struct {
+				name string
+				dst  *string
+			}{
+				{name: address, dst: &d.Address},
+				{name: driverName, dst: &d.Driver},
+				{name: mode, dst: &d.Mode},
+			} {
+				*attr.dst, err = inventoryAttr(root, path, n, attr.name)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for _, attr := range []struct {
+				name string
+				dst  *[]string
+			}{
+				{name: modes, dst: &d.Modes},
+				{name: commands, dst: &d.Commands},
+			} {
+				s, err := inventoryAttr(root, path, n, attr.name)
+				if err != nil {
+					return nil, err
+				}
+				if s != "" {
+					*attr.dst = strings.Split(s, " ")
+				}
+			}
+			inv = append(inv, d)
+		}
+	}
+	sort.Sort(inv)
+	return inv, nil
+}
+
+// inventoryAttr returns the value of the named attribute of the device.
+// Attributes that do not exist for the device class, or that disappear
+// with the device, are returned empty.
+func inventoryAttr(root, path, name, attr string) (string, error) {
+	b, err := backend.Read(filepath.Join(root, path, name, attr))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("ev3dev: could not read %s for %s %s: %v", attr, filepath.Base(path), name, err)
+	}
+	if len(b) == 0 {
+		return "", nil
+	}
+	return string(chomp(b)), nil
+}
+
+// InventoryDiff is a difference between two device inventories. If Have
+// is nil the wanted device is missing, and if Want is nil the device is
+// not expected.
+type InventoryDiff struct {
+	Have *DeviceInfo
+	Want *DeviceInfo
+}
+
+// String satisfies the fmt.Stringer interface.
+func (d InventoryDiff) String() string {
+	switch {
+	case d.Have == nil:
+		return fmt.Sprintf("missing %v", d.Want)
+	case d.Want == nil:
+		return fmt.Sprintf("unexpected %v", d.Have)
+	default:
+		return fmt.Sprintf("have %+v want %+v", *d.Have, *d.Want)
+	}
+}
+
+// Diff returns the differences between the inventory and the wanted
+// inventory. Devices are matched by class and address, or by class and
+// name for devices without an address, and matched devices differ if
+// their driver, mode, modes or commands differ. Device names are not
+// compared for devices with an address, so a saved inventory remains
+// valid when the kernel enumerates devices in a different order.
+func (inv DeviceInventory) Diff(want DeviceInventory) []InventoryDiff {
+	have := make(map[string]*DeviceInfo, len(inv))
+	for i := range inv {
+		have[inv[i].key()] = &inv[i]
+	}
+	wanted := make(map[string]bool, len(want))
+
+	var diffs []InventoryDiff
+	for i := range want {
+		w := &want[i]
+		k := w.key()
+		wanted[k] = true
+		h, ok := have[k]
+		switch {
+		case !ok:
+			diffs = append(diffs, InventoryDiff{Want: w})
+		case !sameDevice(*h, *w):
+			diffs = append(diffs, InventoryDiff{Have: h, Want: w})
+		}
+	}
+	for i := range inv {
+		if !wanted[inv[i].key()] {
+			diffs = append(diffs, InventoryDiff{Have: &inv[i]})
+		}
+	}
+	return diffs
+}
+
+func sameDevice(a, b DeviceInfo) bool {
+	if a.Address != "" {
+		a.Name, b.Name = "", ""
+	}
+	if len(a.Modes) == 0 {
+		a.Modes = nil
+	}
+	if len(b.Modes) == 0 {
+		b.Modes = nil
+	}
+	if len(a.Commands) == 0 {
+		a.Commands = nil
+	}
+	if len(b.Commands) == 0 {
+		b.Commands = nil
+	}
+	return reflect.DeepEqual(a, b)
+}
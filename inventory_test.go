@@ -0,0 +1,103 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInventory(t *testing.T) {
+	root, err := ioutil.TempDir("", "ev3dev-inventory")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	for path, attrs := range map[string]map[string]string{
+		filepath.Join(TachoMotorPath, "motor1"): {
+			address:    "outB",
+			driverName: "lego-ev3-l-motor",
+			commands:   "run-forever run-to-abs-pos run-to-rel-pos run-timed run-direct stop reset",
+		},
+		filepath.Join(TachoMotorPath, "motor0"): {
+			address:    "outA",
+			driverName: "lego-ev3-m-motor",
+			commands:   "run-forever run-to-abs-pos run-to-rel-pos run-timed run-direct stop reset",
+		},
+		filepath.Join(SensorPath, "sensor0"): {
+			address:    "in1",
+			driverName: "lego-ev3-touch",
+			mode:       "TOUCH",
+			modes:      "TOUCH",
+			commands:   "",
+		},
+		filepath.Join(LEDPath, "led0:green:brick-status"): {
+			brightness: "0",
+		},
+	} {
+		dir := filepath.Join(root, path)
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			t.Fatalf("failed to create device directory: %v", err)
+		}
+		for attr, val := range attrs {
+			err = ioutil.WriteFile(filepath.Join(dir, attr), []byte(val+"\n"), 0644)
+			if err != nil {
+				t.Fatalf("failed to write %s: %v", attr, err)
+			}
+		}
+	}
+
+	inv, err := inventoryOf(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	motorCommands := []string{"run-forever", "run-to-abs-pos", "run-to-rel-pos", "run-timed", "run-direct", "stop", "reset"}
+	want := DeviceInventory{
+		{Class: "leds", Name: "led0:green:brick-status"},
+		{Class: "lego-sensor", Name: "sensor0", Address: "in1", Driver: "lego-ev3-touch", Mode: "TOUCH", Modes: []string{"TOUCH"}},
+		{Class: "tacho-motor", Name: "motor0", Address: "outA", Driver: "lego-ev3-m-motor", Commands: motorCommands},
+		{Class: "tacho-motor", Name: "motor1", Address: "outB", Driver: "lego-ev3-l-motor", Commands: motorCommands},
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Errorf("unexpected inventory:\ngot: %+v\nwant:%+v", inv, want)
+	}
+
+	b, err := json.Marshal(inv)
+	if err != nil {
+		t.Fatalf("unexpected error marshaling inventory: %v", err)
+	}
+	var saved DeviceInventory
+	err = json.Unmarshal(b, &saved)
+	if err != nil {
+		t.Fatalf("unexpected error unmarshaling inventory: %v", err)
+	}
+	if diffs := inv.Diff(saved); len(diffs) != 0 {
+		t.Errorf("unexpected differences from saved inventory: %v", diffs)
+	}
+
+	// Renumbered devices are not different.
+	saved[2].Name, saved[3].Name = "motor3", "motor2"
+	if diffs := inv.Diff(saved); len(diffs) != 0 {
+		t.Errorf("unexpected differences from renumbered inventory: %v", diffs)
+	}
+
+	saved[1].Driver = "lego-ev3-color"
+	saved = append(saved[:3], DeviceInfo{Class: "tacho-motor", Name: "motor4", Address: "outC", Driver: "lego-ev3-l-motor"})
+	diffs := inv.Diff(saved)
+	wantDiffs := []InventoryDiff{
+		{Have: &inv[1], Want: &saved[1]},
+		{Want: &saved[3]},
+		{Have: &inv[3]},
+	}
+	if !reflect.DeepEqual(diffs, wantDiffs) {
+		t.Errorf("unexpected differences:\ngot: %v\nwant:%v", diffs, wantDiffs)
+	}
+}
@@ -6,7 +6,6 @@ package ev3dev
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 )
@@ -115,12 +114,7 @@ func ConnectedTo(p *LegoPort) (string, error) {
 	if p.id < 0 {
 		return "", fmt.Errorf("ev3dev: invalid lego port number: %d", p.id)
 	}
-	f, err := os.Open(filepath.Join(p.Path(), p.String()))
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-	names, err := f.Readdirnames(0)
+	names, err := backend.List(filepath.Join(p.Path(), p.String()))
 	if err != nil {
 		return "", err
 	}
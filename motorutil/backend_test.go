@@ -0,0 +1,88 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// recorder is a MemoryBackend that records the attribute writes made
+// through it as "device/attribute=value" strings.
+type recorder struct {
+	*ev3dev.MemoryBackend
+
+	mu     sync.Mutex
+	writes []string
+}
+
+// useRecorder installs a new recorder as the ev3dev attribute Backend.
+// The returned function restores the previous Backend.
+func useRecorder() (b *recorder, restore func()) {
+	b = &recorder{MemoryBackend: ev3dev.NewMemoryBackend()}
+	prev := ev3dev.SetBackend(b)
+	return b, func() { ev3dev.SetBackend(prev) }
+}
+
+func (b *recorder) Write(path string, data []byte) error {
+	b.mu.Lock()
+	b.writes = append(b.writes, fmt.Sprintf("%s/%s=%s", filepath.Base(filepath.Dir(path)), filepath.Base(path), strings.TrimSpace(string(data))))
+	b.mu.Unlock()
+	return b.MemoryBackend.Write(path, data)
+}
+
+// Writes returns the writes recorded so far and clears the record.
+func (b *recorder) Writes() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w := b.writes
+	b.writes = nil
+	return w
+}
+
+// set sets the attribute of the named tacho motor without recording
+// the write.
+func (b *recorder) set(t *testing.T, motor, attr, val string) {
+	err := b.MemoryBackend.Write(filepath.Join(ev3dev.TachoMotorPath, motor, attr), []byte(val+"\n"))
+	if err != nil {
+		t.Fatalf("unexpected error writing %s/%s: %v", motor, attr, err)
+	}
+}
+
+// get returns the attribute of the named tacho motor.
+func (b *recorder) get(t *testing.T, motor, attr string) string {
+	data, err := b.MemoryBackend.Read(filepath.Join(ev3dev.TachoMotorPath, motor, attr))
+	if err != nil {
+		t.Fatalf("unexpected error reading %s/%s: %v", motor, attr, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// addMotor adds a large tacho motor named motor on the given port to
+// the recorder and returns a handle to it.
+func (b *recorder) addMotor(t *testing.T, motor, port string) *ev3dev.TachoMotor {
+	for _, attr := range []struct{ name, val string }{
+		{"address", port},
+		{"driver_name", "lego-ev3-l-motor"},
+		{"commands", "run-forever run-to-abs-pos run-to-rel-pos run-timed run-direct stop reset"},
+		{"stop_actions", "coast brake hold"},
+		{"stop_action", "coast"},
+		{"count_per_rot", "360"},
+		{"position", "0"},
+		{"state", ""},
+	} {
+		b.set(t, motor, attr.name, attr.val)
+	}
+	m, err := ev3dev.TachoMotorFor(port, "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor on %s: %v", port, err)
+	}
+	return m
+}
@@ -0,0 +1,217 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+// DriveBase is a differential drive robot base with two independently
+// driven wheels. The motors must be configured such that a positive
+// speed moves the robot forward, for example by setting the polarity
+// of one of the motors to ev3dev.Inversed.
+//
+// Distances and speeds are in the length units used to specify the
+// wheel diameter and track width, and angles are in radians. Positive
+// angles turn the robot counter-clockwise when viewed from above.
+type DriveBase struct {
+	left, right *ev3dev.TachoMotor
+
+	// countPerLength is the number of
+	// tacho counts per unit travel for
+	// the left and right wheels.
+	leftCountPerLength  float64
+	rightCountPerLength float64
+
+	wheelDiameter float64
+	trackWidth    float64
+}
+
+// NewDriveBase returns a DriveBase using the given left and right motors
+// with wheels of the given diameter, separated by trackWidth.
+func NewDriveBase(left, right *ev3dev.TachoMotor, wheelDiameter, trackWidth float64) (*DriveBase, error) {
+	if left == nil || right == nil {
+		return nil, errors.New("motorutil: nil motor")
+	}
+	if wheelDiameter <= 0 {
+		return nil, fmt.Errorf("motorutil: invalid wheel diameter: %v", wheelDiameter)
+	}
+	if trackWidth <= 0 {
+		return nil, fmt.Errorf("motorutil: invalid track width: %v", trackWidth)
+	}
+	lcpr, err := left.CountPerRot()
+	if err != nil {
+		return nil, err
+	}
+	rcpr, err := right.CountPerRot()
+	if err != nil {
+		return nil, err
+	}
+	circ := math.Pi * wheelDiameter
+	return &DriveBase{
+		left:  left,
+		right: right,
+
+		leftCountPerLength:  float64(lcpr) / circ,
+		rightCountPerLength: float64(rcpr) / circ,
+
+		wheelDiameter: wheelDiameter,
+		trackWidth:    trackWidth,
+	}, nil
+}
+
+// Motors returns the left and right motors of the DriveBase.
+func (d *DriveBase) Motors() (left, right *ev3dev.TachoMotor) { return d.left, d.right }
+
+// WheelDiameter returns the wheel diameter of the DriveBase.
+func (d *DriveBase) WheelDiameter() float64 { return d.wheelDiameter }
+
+// TrackWidth returns the track width of the DriveBase.
+func (d *DriveBase) TrackWidth() float64 { return d.trackWidth }
+
+// Tank runs the left and right wheels indefinitely at the given
+// speeds.
+func (d *DriveBase) Tank(left, right float64) error {
+	lsp := round.Int(left * d.leftCountPerLength)
+	rsp := round.Int(right * d.rightCountPerLength)
+	err := d.left.SetSpeedSetpoint(lsp).Err()
+	if err != nil {
+		return err
+	}
+	err = d.right.SetSpeedSetpoint(rsp).Err()
+	if err != nil {
+		return err
+	}
+	err = d.left.Command("run-forever").Err()
+	if err != nil {
+		return err
+	}
+	return d.right.Command("run-forever").Err()
+}
+
+// Steering runs the DriveBase indefinitely with the given steering and
+// speed. Steering is in the range [-100, 100]. A steering value of zero
+// drives straight, positive values steer to the right and negative values
+// to the left. The outer wheel is driven at speed, and the inner wheel
+// is slowed, stopping at a steering magnitude of 50 and reversing to
+// turn in place at 100.
+func (d *DriveBase) Steering(steering, speed float64) error {
+	if steering < -100 || 100 < steering {
+		return fmt.Errorf("motorutil: invalid steering value: %v (must be in -100-100)", steering)
+	}
+	return d.Tank(steer(steering, speed))
+}
+
+// steer returns the left and right wheel speeds for the given steering
+// value and speed.
+func steer(steering, speed float64) (left, right float64) {
+	inner := speed * (50 - math.Abs(steering)) / 50
+	if steering < 0 {
+		return inner, speed
+	}
+	return speed, inner
+}
+
+// Drive drives the DriveBase straight for the given distance at the given
+// speed, blocking until the motion has completed or the timeout has passed.
+// A negative distance drives backwards. If timeout is negative, Drive waits
+// indefinitely for the motion to complete.
+func (d *DriveBase) Drive(distance, speed float64, timeout time.Duration) error {
+	return d.runToRel(distance, distance, speed, speed, timeout)
+}
+
+// Turn turns the DriveBase in place by the given angle, with the wheels
+// moving at the given speed, blocking until the motion has completed or
+// the timeout has passed. If timeout is negative, Turn waits indefinitely
+// for the motion to complete.
+func (d *DriveBase) Turn(angle, speed float64, timeout time.Duration) error {
+	arc := angle * d.trackWidth / 2
+	return d.runToRel(-arc, arc, speed, speed, timeout)
+}
+
+// Stop issues a stop command to both motors of the DriveBase.
+func (d *DriveBase) Stop() error {
+	err := d.left.Command("stop").Err()
+	if err != nil {
+		d.right.Command("stop")
+		return err
+	}
+	return d.right.Command("stop").Err()
+}
+
+func (d *DriveBase) runToRel(left, right, lspeed, rspeed float64, timeout time.Duration) error {
+	lpos := round.Int(left * d.leftCountPerLength)
+	rpos := round.Int(right * d.rightCountPerLength)
+	lsp := round.Int(math.Abs(lspeed) * d.leftCountPerLength)
+	rsp := round.Int(math.Abs(rspeed) * d.rightCountPerLength)
+	err := d.left.SetPositionSetpoint(lpos).SetSpeedSetpoint(lsp).Err()
+	if err != nil {
+		return err
+	}
+	err = d.right.SetPositionSetpoint(rpos).SetSpeedSetpoint(rsp).Err()
+	if err != nil {
+		return err
+	}
+	err = d.left.Command("run-to-rel-pos").Err()
+	if err != nil {
+		return err
+	}
+	err = d.right.Command("run-to-rel-pos").Err()
+	if err != nil {
+		return err
+	}
+
+	end := time.Now().Add(timeout)
+	for _, m := range []*ev3dev.TachoMotor{d.left, d.right} {
+		remain := timeout
+		if timeout >= 0 {
+			remain = end.Sub(time.Now())
+			if remain < 0 {
+				remain = 0
+			}
+		}
+		err = waitStopped(m, remain)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitStopped waits until the motor is no longer running, or is holding
+// or stalled.
+func waitStopped(m ev3dev.StaterDevice, timeout time.Duration) error {
+	const slice = 100 * time.Millisecond
+
+	end := time.Now().Add(timeout)
+	for {
+		wait := slice
+		if timeout >= 0 {
+			remain := end.Sub(time.Now())
+			if remain < wait {
+				wait = remain
+			}
+			if wait < 0 {
+				wait = 0
+			}
+		}
+		stat, ok, err := ev3dev.Wait(m, ev3dev.Running, 0, 0, false, wait)
+		if err != nil {
+			return err
+		}
+		if ok || stat&(ev3dev.Holding|ev3dev.Stalled) != 0 {
+			return nil
+		}
+		if timeout >= 0 && !time.Now().Before(end) {
+			return fmt.Errorf("motorutil: timed out waiting for %s to stop", m)
+		}
+	}
+}
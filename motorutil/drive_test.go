@@ -0,0 +1,196 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+var steerTests = []struct {
+	steering, speed float64
+
+	wantLeft, wantRight float64
+}{
+	{steering: 0, speed: 100, wantLeft: 100, wantRight: 100},
+	{steering: 25, speed: 100, wantLeft: 100, wantRight: 50},
+	{steering: -25, speed: 100, wantLeft: 50, wantRight: 100},
+	{steering: 50, speed: 100, wantLeft: 100, wantRight: 0},
+	{steering: 100, speed: 100, wantLeft: 100, wantRight: -100},
+	{steering: -100, speed: 100, wantLeft: -100, wantRight: 100},
+	{steering: 50, speed: -80, wantLeft: -80, wantRight: 0},
+}
+
+func TestSteer(t *testing.T) {
+	for _, test := range steerTests {
+		left, right := steer(test.steering, test.speed)
+		if left != test.wantLeft || right != test.wantRight {
+			t.Errorf("unexpected wheel speeds for steering=%v speed=%v: got:(%v, %v) want:(%v, %v)",
+				test.steering, test.speed, left, right, test.wantLeft, test.wantRight)
+		}
+	}
+}
+
+// newTestDriveBase returns a DriveBase on motor0 and motor1 of b with
+// one tacho count per unit length and a track width of 100.
+func newTestDriveBase(t *testing.T, b *recorder) *DriveBase {
+	left := b.addMotor(t, "motor0", "outA")
+	right := b.addMotor(t, "motor1", "outB")
+	d, err := NewDriveBase(left, right, 360/math.Pi, 100)
+	if err != nil {
+		t.Fatalf("unexpected error creating drive base: %v", err)
+	}
+	b.Writes()
+	return d
+}
+
+var driveBaseTests = []struct {
+	name string
+	run  func(d *DriveBase) error
+	want []string
+}{
+	{
+		name: "tank",
+		run:  func(d *DriveBase) error { return d.Tank(100, -50.4) },
+		want: []string{
+			"motor0/speed_sp=100",
+			"motor1/speed_sp=-50",
+			"motor0/command=run-forever",
+			"motor1/command=run-forever",
+		},
+	},
+	{
+		name: "steering",
+		run:  func(d *DriveBase) error { return d.Steering(25, 200) },
+		want: []string{
+			"motor0/speed_sp=200",
+			"motor1/speed_sp=100",
+			"motor0/command=run-forever",
+			"motor1/command=run-forever",
+		},
+	},
+	{
+		name: "drive",
+		run:  func(d *DriveBase) error { return d.Drive(250, 100, time.Second) },
+		want: []string{
+			"motor0/position_sp=250",
+			"motor0/speed_sp=100",
+			"motor1/position_sp=250",
+			"motor1/speed_sp=100",
+			"motor0/command=run-to-rel-pos",
+			"motor1/command=run-to-rel-pos",
+		},
+	},
+	{
+		name: "drive backwards",
+		run:  func(d *DriveBase) error { return d.Drive(-250, -100, time.Second) },
+		want: []string{
+			"motor0/position_sp=-250",
+			"motor0/speed_sp=100",
+			"motor1/position_sp=-250",
+			"motor1/speed_sp=100",
+			"motor0/command=run-to-rel-pos",
+			"motor1/command=run-to-rel-pos",
+		},
+	},
+	{
+		name: "turn",
+		run:  func(d *DriveBase) error { return d.Turn(math.Pi/2, 100, time.Second) },
+		want: []string{
+			"motor0/position_sp=-79",
+			"motor0/speed_sp=100",
+			"motor1/position_sp=79",
+			"motor1/speed_sp=100",
+			"motor0/command=run-to-rel-pos",
+			"motor1/command=run-to-rel-pos",
+		},
+	},
+	{
+		name: "stop",
+		run:  func(d *DriveBase) error { return d.Stop() },
+		want: []string{
+			"motor0/command=stop",
+			"motor1/command=stop",
+		},
+	},
+}
+
+func TestDriveBase(t *testing.T) {
+	for _, test := range driveBaseTests {
+		b, restore := useRecorder()
+		d := newTestDriveBase(t, b)
+		err := test.run(d)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+		}
+		if got := b.Writes(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected writes for %s:\ngot: %q\nwant:%q", test.name, got, test.want)
+		}
+		restore()
+	}
+}
+
+func TestDriveBaseTimeout(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	d := newTestDriveBase(t, b)
+	b.set(t, "motor1", "state", "running")
+	err := d.Drive(100, 100, 20*time.Millisecond)
+	if err == nil {
+		t.Error("expected timeout error for running motor")
+	}
+}
+
+var waitStoppedTests = []struct {
+	state   string
+	change  bool
+	later   string
+	timeout time.Duration
+
+	wantErr bool
+}{
+	{state: "", timeout: time.Second},
+	{state: "holding", timeout: time.Second},
+	{state: "running stalled", timeout: time.Second},
+	{state: "running", change: true, later: "", timeout: time.Second},
+	{state: "running", change: true, later: "running holding", timeout: time.Second},
+	{state: "running", timeout: 20 * time.Millisecond, wantErr: true},
+	{state: "running ramping", timeout: 0, wantErr: true},
+}
+
+func TestWaitStopped(t *testing.T) {
+	for _, test := range waitStoppedTests {
+		b, restore := useRecorder()
+		m := b.addMotor(t, "motor0", "outA")
+		b.set(t, "motor0", "state", test.state)
+		done := make(chan struct{})
+		if test.change {
+			go func(state string) {
+				defer close(done)
+				time.Sleep(20 * time.Millisecond)
+				err := b.MemoryBackend.Write(filepath.Join(ev3dev.TachoMotorPath, "motor0", "state"), []byte(state+"\n"))
+				if err != nil {
+					t.Errorf("unexpected error writing state: %v", err)
+				}
+			}(test.later)
+		} else {
+			close(done)
+		}
+
+		err := waitStopped(m, test.timeout)
+		if (err != nil) != test.wantErr {
+			t.Errorf("unexpected error for state %q (change=%t to %q) with timeout %v: got:%v want error:%t",
+				test.state, test.change, test.later, test.timeout, err, test.wantErr)
+		}
+		<-done
+		restore()
+	}
+}
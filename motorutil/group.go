@@ -0,0 +1,259 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Target is a position and speed setpoint for a motor.
+type Target struct {
+	Position int
+	Speed    int
+}
+
+// MotorGroup is a set of TachoMotors that are commanded together.
+//
+// Setpoints for all members are written before any command is issued,
+// and commands are then written to all members back-to-back, so that
+// the motors start as close to simultaneously as possible.
+type MotorGroup struct {
+	motors []*ev3dev.TachoMotor
+}
+
+// NewMotorGroup returns a MotorGroup holding the given motors.
+func NewMotorGroup(motors ...*ev3dev.TachoMotor) (*MotorGroup, error) {
+	if len(motors) == 0 {
+		return nil, errors.New("motorutil: no motors in group")
+	}
+	for i, m := range motors {
+		if m == nil {
+			return nil, fmt.Errorf("motorutil: nil motor at index %d", i)
+		}
+	}
+	return &MotorGroup{motors: append([]*ev3dev.TachoMotor(nil), motors...)}, nil
+}
+
+// Motors returns the motors in the MotorGroup.
+func (g *MotorGroup) Motors() []*ev3dev.TachoMotor {
+	return append([]*ev3dev.TachoMotor(nil), g.motors...)
+}
+
+// RunToRelPos runs the motors in the MotorGroup to the positions relative
+// to their current positions given by the targets. If a single target is
+// given, it is used for all motors, otherwise there must be one target
+// for each motor.
+func (g *MotorGroup) RunToRelPos(targets ...Target) error {
+	return g.runTo("run-to-rel-pos", targets)
+}
+
+// RunToAbsPos runs the motors in the MotorGroup to the absolute positions
+// given by the targets. If a single target is given, it is used for all
+// motors, otherwise there must be one target for each motor.
+func (g *MotorGroup) RunToAbsPos(targets ...Target) error {
+	return g.runTo("run-to-abs-pos", targets)
+}
+
+// RunForever runs the motors in the MotorGroup indefinitely at the given
+// speeds. If a single speed is given, it is used for all motors, otherwise
+// there must be one speed for each motor.
+func (g *MotorGroup) RunForever(speeds ...int) error {
+	if len(speeds) != 1 && len(speeds) != len(g.motors) {
+		return fmt.Errorf("motorutil: mismatched speed count: %d for %d motors", len(speeds), len(g.motors))
+	}
+	for i, m := range g.motors {
+		sp := speeds[0]
+		if len(speeds) != 1 {
+			sp = speeds[i]
+		}
+		err := m.SetSpeedSetpoint(sp).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return g.Command("run-forever")
+}
+
+// Stop issues a stop command to all the motors in the MotorGroup.
+func (g *MotorGroup) Stop() error {
+	return g.Command("stop")
+}
+
+func (g *MotorGroup) runTo(comm string, targets []Target) error {
+	if len(targets) != 1 && len(targets) != len(g.motors) {
+		return fmt.Errorf("motorutil: mismatched target count: %d for %d motors", len(targets), len(g.motors))
+	}
+	for i, m := range g.motors {
+		t := targets[0]
+		if len(targets) != 1 {
+			t = targets[i]
+		}
+		err := m.SetPositionSetpoint(t.Position).SetSpeedSetpoint(t.Speed).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return g.Command(comm)
+}
+
+// Command issues the command to all the motors in the MotorGroup. The
+// command is checked against the available commands of each motor and
+// the command attributes of all motors are opened before the command is
+// written to any motor. If the ev3dev attribute Backend is not the file
+// system, the command is written to each motor in turn after all the
+// commands have been checked.
+func (g *MotorGroup) Command(comm string) error {
+	for _, m := range g.motors {
+		avail, err := m.Commands()
+		if err != nil {
+			return err
+		}
+		ok := false
+		for _, c := range avail {
+			if c == comm {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("motorutil: command %q not available for %s (available:%q)", comm, m, avail)
+		}
+	}
+
+	b := []byte(comm)
+	var errs Errors
+	if ev3dev.IsFileBackend(ev3dev.CurrentBackend()) {
+		files := make([]*os.File, 0, len(g.motors))
+		defer func() {
+			for _, f := range files {
+				f.Close()
+			}
+		}()
+		for _, m := range g.motors {
+			f, err := os.OpenFile(filepath.Join(m.Path(), m.String(), "command"), os.O_WRONLY, 0)
+			if err != nil {
+				return fmt.Errorf("motorutil: failed to open command attribute for %s: %v", m, err)
+			}
+			files = append(files, f)
+		}
+		for i, f := range files {
+			_, err := f.Write(b)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("motorutil: failed to issue command %q to %s: %v", comm, g.motors[i], err))
+			}
+		}
+	} else {
+		backend := ev3dev.CurrentBackend()
+		for _, m := range g.motors {
+			err := backend.Write(filepath.Join(m.Path(), m.String(), "command"), b)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("motorutil: failed to issue command %q to %s: %v", comm, m, err))
+			}
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
+
+// WaitAll blocks until all the motors in the MotorGroup reach the wanted
+// motor state under the motor state mask, or the timeout is reached. The
+// semantics of mask, want, not, any and timeout are the same as for
+// ev3dev.Wait. The last read motor state of each motor is returned with
+// ok true if all motors reached the wanted state.
+func (g *MotorGroup) WaitAll(mask, want, not ev3dev.MotorState, any bool, timeout time.Duration) (stat []ev3dev.MotorState, ok bool, err error) {
+	stat = make([]ev3dev.MotorState, len(g.motors))
+	oks := make([]bool, len(g.motors))
+	errs := make([]error, len(g.motors))
+	var wg sync.WaitGroup
+	for i, m := range g.motors {
+		wg.Add(1)
+		go func(i int, m *ev3dev.TachoMotor) {
+			defer wg.Done()
+			stat[i], oks[i], errs[i] = ev3dev.Wait(m, mask, want, not, any, timeout)
+		}(i, m)
+	}
+	wg.Wait()
+
+	ok = true
+	var failed Errors
+	for i := range g.motors {
+		ok = ok && oks[i]
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return stat, ok, nil
+	case 1:
+		return stat, false, failed[0]
+	default:
+		return stat, false, failed
+	}
+}
+
+// WaitAny blocks until any of the motors in the MotorGroup reaches the wanted
+// motor state under the motor state mask, or the timeout is reached. The
+// semantics of mask, want, not, any and timeout are the same as for
+// ev3dev.Wait. The index of the first motor to reach the wanted state and
+// its state are returned with ok true, otherwise the returned index is -1.
+func (g *MotorGroup) WaitAny(mask, want, not ev3dev.MotorState, any bool, timeout time.Duration) (index int, stat ev3dev.MotorState, ok bool, err error) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout < 0 {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	defer cancel()
+
+	type result struct {
+		index int
+		stat  ev3dev.MotorState
+		ok    bool
+		err   error
+	}
+	results := make(chan result, len(g.motors))
+	var wg sync.WaitGroup
+	for i, m := range g.motors {
+		wg.Add(1)
+		go func(i int, m *ev3dev.TachoMotor) {
+			defer wg.Done()
+			stat, ok, err := ev3dev.WaitContext(ctx, m, mask, want, not, any)
+			results <- result{index: i, stat: stat, ok: ok, err: err}
+		}(i, m)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for range g.motors {
+		r := <-results
+		switch {
+		case r.ok:
+			return r.index, r.stat, true, nil
+		case r.err != nil && r.err != ctx.Err():
+			return -1, r.stat, false, r.err
+		}
+	}
+	return -1, 0, false, nil
+}
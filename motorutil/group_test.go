@@ -0,0 +1,136 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+func TestMotorGroupMismatch(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	g, err := NewMotorGroup(b.addMotor(t, "motor0", "outA"), b.addMotor(t, "motor1", "outB"))
+	if err != nil {
+		t.Fatalf("unexpected error creating group: %v", err)
+	}
+	b.Writes()
+
+	err = g.RunToRelPos(Target{Position: 1}, Target{Position: 2}, Target{Position: 3})
+	if err == nil {
+		t.Error("expected error for mismatched target count")
+	}
+	err = g.RunToAbsPos(Target{Position: 1}, Target{Position: 2}, Target{Position: 3})
+	if err == nil {
+		t.Error("expected error for mismatched target count")
+	}
+	err = g.RunForever(100, 200, 300)
+	if err == nil {
+		t.Error("expected error for mismatched speed count")
+	}
+	if got := b.Writes(); len(got) != 0 {
+		t.Errorf("unexpected writes after mismatch: %q", got)
+	}
+}
+
+func TestMotorGroupSetpointsBeforeCommands(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	g, err := NewMotorGroup(b.addMotor(t, "motor0", "outA"), b.addMotor(t, "motor1", "outB"))
+	if err != nil {
+		t.Fatalf("unexpected error creating group: %v", err)
+	}
+	b.Writes()
+
+	err = g.RunToRelPos(Target{Position: 100, Speed: 200}, Target{Position: -50, Speed: 300})
+	if err != nil {
+		t.Fatalf("unexpected error running group: %v", err)
+	}
+	want := []string{
+		"motor0/position_sp=100",
+		"motor0/speed_sp=200",
+		"motor1/position_sp=-50",
+		"motor1/speed_sp=300",
+		"motor0/command=run-to-rel-pos",
+		"motor1/command=run-to-rel-pos",
+	}
+	if got := b.Writes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected writes for RunToRelPos:\ngot: %q\nwant:%q", got, want)
+	}
+
+	err = g.RunForever(500)
+	if err != nil {
+		t.Fatalf("unexpected error running group: %v", err)
+	}
+	want = []string{
+		"motor0/speed_sp=500",
+		"motor1/speed_sp=500",
+		"motor0/command=run-forever",
+		"motor1/command=run-forever",
+	}
+	if got := b.Writes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected writes for RunForever:\ngot: %q\nwant:%q", got, want)
+	}
+}
+
+func TestMotorGroupWaitAny(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	g, err := NewMotorGroup(
+		b.addMotor(t, "motor0", "outA"),
+		b.addMotor(t, "motor1", "outB"),
+		b.addMotor(t, "motor2", "outC"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error creating group: %v", err)
+	}
+	for _, m := range []string{"motor0", "motor1", "motor2"} {
+		b.set(t, m, "state", "running")
+	}
+
+	before := runtime.NumGoroutine()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		err := b.MemoryBackend.Write(filepath.Join(ev3dev.TachoMotorPath, "motor1", "state"), []byte("holding\n"))
+		if err != nil {
+			t.Errorf("unexpected error writing state: %v", err)
+		}
+	}()
+	i, stat, ok, err := g.WaitAny(ev3dev.Running, 0, 0, false, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error waiting for group: %v", err)
+	}
+	if !ok || i != 1 || stat != ev3dev.Holding {
+		t.Errorf("unexpected wait result: got:(%d, %v, %t) want:(1, %v, true)", i, stat, ok, ev3dev.Holding)
+	}
+
+	// Allow the state setting goroutine to exit.
+	end := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(end) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("leaked goroutines after WaitAny: got:%d want:<=%d", n, before)
+	}
+
+	i, _, ok, err = g.WaitAny(ev3dev.Stalled, ev3dev.Stalled, 0, false, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error waiting for group: %v", err)
+	}
+	if ok || i != -1 {
+		t.Errorf("unexpected wait result after timeout: got:(%d, %t) want:(-1, false)", i, ok)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("leaked goroutines after WaitAny timeout: got:%d want:<=%d", n, before)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Pose is a two-dimensional position and heading estimate.
+type Pose struct {
+	// Time is the time of the estimate.
+	Time time.Time
+
+	// X and Y are the position in the
+	// length units of the DriveBase.
+	X, Y float64
+
+	// Heading is the heading in radians,
+	// counter-clockwise from the X axis,
+	// in the range (-π, π].
+	Heading float64
+}
+
+// Odometer estimates the pose of a DriveBase by integrating the wheel
+// encoder positions sampled at a fixed rate.
+//
+// The Odometer samples using its own copies of the DriveBase motor
+// handles, so the DriveBase may be commanded while the Odometer is
+// running.
+type Odometer struct {
+	left, right ev3dev.TachoMotor
+
+	leftLengthPerCount  float64
+	rightLengthPerCount float64
+	trackWidth          float64
+
+	mu        sync.Mutex
+	pose      Pose
+	lastLeft  int32
+	lastRight int32
+	history   []Pose
+	next      int
+	full      bool
+	err       error
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewOdometer returns a running Odometer for the DriveBase, sampling the
+// wheel positions every period and retaining the given number of past
+// pose estimates. The initial pose is at the origin with a zero heading.
+func NewOdometer(d *DriveBase, period time.Duration, history int) (*Odometer, error) {
+	if d == nil {
+		return nil, errors.New("motorutil: nil drive base")
+	}
+	if period <= 0 {
+		return nil, fmt.Errorf("motorutil: invalid sample period: %v", period)
+	}
+	if history < 0 {
+		return nil, fmt.Errorf("motorutil: invalid history length: %d", history)
+	}
+	o := &Odometer{
+		left:  *d.left,
+		right: *d.right,
+
+		leftLengthPerCount:  1 / d.leftCountPerLength,
+		rightLengthPerCount: 1 / d.rightCountPerLength,
+		trackWidth:          d.trackWidth,
+
+		history: make([]Pose, history),
+		done:    make(chan struct{}),
+	}
+	err := o.Reset(Pose{})
+	if err != nil {
+		return nil, err
+	}
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		tick := time.NewTicker(period)
+		defer tick.Stop()
+		for {
+			select {
+			case <-o.done:
+				return
+			case <-tick.C:
+				o.sample()
+			}
+		}
+	}()
+	return o, nil
+}
+
+// positions returns the current left and right wheel positions.
+// o.mu must be held.
+func (o *Odometer) positions() (left, right int32, err error) {
+	l, err := o.left.Position()
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := o.right.Position()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int32(l), int32(r), nil
+}
+
+func (o *Odometer) sample() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, r, err := o.positions()
+	now := time.Now()
+	if err != nil {
+		o.err = err
+		return
+	}
+	dl := float64(countDelta(l, o.lastLeft)) * o.leftLengthPerCount
+	dr := float64(countDelta(r, o.lastRight)) * o.rightLengthPerCount
+	o.lastLeft, o.lastRight = l, r
+	o.pose = integrate(o.pose, dl, dr, o.trackWidth)
+	o.pose.Time = now
+	o.record(o.pose)
+}
+
+// record adds p to the history ring. o.mu must be held.
+func (o *Odometer) record(p Pose) {
+	if len(o.history) == 0 {
+		return
+	}
+	o.history[o.next] = p
+	o.next++
+	if o.next == len(o.history) {
+		o.next = 0
+		o.full = true
+	}
+}
+
+// countDelta returns the change in tacho count from last to cur, allowing
+// for wrapping of the int32 position attribute.
+func countDelta(cur, last int32) int32 {
+	return int32(uint32(cur) - uint32(last))
+}
+
+// integrate returns the pose p advanced by left and right wheel travel
+// dl and dr for a drive base with the given track width.
+func integrate(p Pose, dl, dr, trackWidth float64) Pose {
+	dc := (dl + dr) / 2
+	dh := (dr - dl) / trackWidth
+	h := p.Heading + dh/2
+	p.X += dc * math.Cos(h)
+	p.Y += dc * math.Sin(h)
+	p.Heading = normalizeAngle(p.Heading + dh)
+	return p
+}
+
+// normalizeAngle returns a in the range (-π, π].
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	switch {
+	case a <= -math.Pi:
+		a += 2 * math.Pi
+	case a > math.Pi:
+		a -= 2 * math.Pi
+	}
+	return a
+}
+
+// Pose returns the current pose estimate.
+func (o *Odometer) Pose() Pose {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pose
+}
+
+// History returns the retained pose estimates, oldest first.
+func (o *Odometer) History() []Pose {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.full {
+		return append([]Pose(nil), o.history[:o.next]...)
+	}
+	h := make([]Pose, 0, len(o.history))
+	h = append(h, o.history[o.next:]...)
+	return append(h, o.history[:o.next]...)
+}
+
+// Reset sets the current pose estimate to p, discarding the pose history.
+// The wheel positions at the time of the call are used as the reference
+// for subsequent estimates. If the time of p is zero, it is set to the
+// current time.
+func (o *Odometer) Reset(p Pose) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	l, r, err := o.positions()
+	if err != nil {
+		return err
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	p.Heading = normalizeAngle(p.Heading)
+	o.pose = p
+	o.lastLeft, o.lastRight = l, r
+	o.next = 0
+	o.full = false
+	o.record(p)
+	return nil
+}
+
+// Err returns the last error that occurred while sampling the wheel
+// positions and clears it.
+func (o *Odometer) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err := o.err
+	o.err = nil
+	return err
+}
+
+// Close stops the Odometer sampling. Close is safe to call more than
+// once and concurrently.
+func (o *Odometer) Close() error {
+	o.once.Do(func() { close(o.done) })
+	o.wg.Wait()
+	return nil
+}
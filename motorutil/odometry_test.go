@@ -0,0 +1,111 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var countDeltaTests = []struct {
+	cur, last int32
+	want      int32
+}{
+	{cur: 10, last: 0, want: 10},
+	{cur: -10, last: 0, want: -10},
+	{cur: math.MinInt32 + 4, last: math.MaxInt32 - 5, want: 10},
+	{cur: math.MaxInt32 - 5, last: math.MinInt32 + 4, want: -10},
+}
+
+func TestCountDelta(t *testing.T) {
+	for _, test := range countDeltaTests {
+		got := countDelta(test.cur, test.last)
+		if got != test.want {
+			t.Errorf("unexpected count delta for cur=%d last=%d: got:%d want:%d", test.cur, test.last, got, test.want)
+		}
+	}
+}
+
+func TestIntegrate(t *testing.T) {
+	const (
+		tol   = 1e-9
+		track = 100.0
+	)
+
+	// Straight line.
+	p := integrate(Pose{}, 10, 10, track)
+	if math.Abs(p.X-10) > tol || math.Abs(p.Y) > tol || math.Abs(p.Heading) > tol {
+		t.Errorf("unexpected pose for straight travel: %+v", p)
+	}
+
+	// Turn in place by a quarter turn.
+	arc := math.Pi / 2 * track / 2
+	p = integrate(Pose{}, -arc, arc, track)
+	if math.Abs(p.X) > tol || math.Abs(p.Y) > tol || math.Abs(p.Heading-math.Pi/2) > tol {
+		t.Errorf("unexpected pose for turn in place: %+v", p)
+	}
+
+	// Drive a full circle of radius r in small steps.
+	const (
+		r     = 200.0
+		steps = 10000
+	)
+	dl := 2 * math.Pi * (r - track/2) / steps
+	dr := 2 * math.Pi * (r + track/2) / steps
+	p = Pose{}
+	for i := 0; i < steps; i++ {
+		p = integrate(p, dl, dr, track)
+	}
+	if math.Abs(p.X) > 1e-6 || math.Abs(p.Y) > 1e-6 || math.Abs(p.Heading) > 1e-6 {
+		t.Errorf("unexpected pose after full circle: %+v", p)
+	}
+}
+
+var normalizeAngleTests = []struct {
+	a, want float64
+}{
+	{a: 0, want: 0},
+	{a: math.Pi, want: math.Pi},
+	{a: -math.Pi, want: math.Pi},
+	{a: 3 * math.Pi / 2, want: -math.Pi / 2},
+	{a: -3 * math.Pi / 2, want: math.Pi / 2},
+	{a: 5 * math.Pi, want: math.Pi},
+}
+
+func TestNormalizeAngle(t *testing.T) {
+	for _, test := range normalizeAngleTests {
+		got := normalizeAngle(test.a)
+		if math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("unexpected normalized angle for %v: got:%v want:%v", test.a, got, test.want)
+		}
+	}
+}
+
+func TestOdometerClose(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	o, err := NewOdometer(newTestDriveBase(t, b), time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("unexpected error creating odometer: %v", err)
+	}
+
+	// Concurrent calls to Close must not panic.
+	closed := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() { closed <- o.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-closed:
+			if err != nil {
+				t.Errorf("unexpected error closing odometer: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for close")
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+// Profile is a symmetric point-to-point motion profile. Distances are
+// in tacho counts and times are in seconds, so speeds are in tacho counts
+// per second.
+//
+// A Profile accelerates from rest to a peak speed, cruises at that speed
+// and decelerates to rest at the target distance. Trapezoidal profiles
+// have constant acceleration phases, and S-curve profiles limit the rate
+// of change of acceleration, jerk, to give smooth acceleration phases.
+type Profile struct {
+	// distance is the absolute distance
+	// to travel and sign is its direction.
+	distance float64
+	sign     float64
+
+	// peak is the peak speed and jerk
+	// is the jerk used during the
+	// acceleration phases.
+	peak float64
+	jerk float64
+
+	// jerkTime is the duration of each
+	// jerk segment in an acceleration
+	// phase, accelTime is the duration
+	// of each acceleration phase and
+	// cruiseTime is the duration of
+	// the constant speed phase.
+	jerkTime   float64
+	accelTime  float64
+	cruiseTime float64
+}
+
+// TrapezoidalProfile returns a trapezoidal velocity profile for traveling
+// the given distance, limited by maxSpeed and maxAccel.
+func TrapezoidalProfile(distance, maxSpeed, maxAccel float64) (*Profile, error) {
+	return newProfile(distance, maxSpeed, maxAccel, math.Inf(1))
+}
+
+// SCurveProfile returns an S-curve velocity profile for traveling the given
+// distance, limited by maxSpeed, maxAccel and maxJerk.
+func SCurveProfile(distance, maxSpeed, maxAccel, maxJerk float64) (*Profile, error) {
+	if !(maxJerk > 0) {
+		return nil, fmt.Errorf("motorutil: invalid maximum jerk: %v", maxJerk)
+	}
+	return newProfile(distance, maxSpeed, maxAccel, maxJerk)
+}
+
+func newProfile(distance, maxSpeed, maxAccel, maxJerk float64) (*Profile, error) {
+	if math.IsNaN(distance) || math.IsInf(distance, 0) {
+		return nil, fmt.Errorf("motorutil: invalid distance: %v", distance)
+	}
+	if !(maxSpeed > 0) || math.IsInf(maxSpeed, 0) {
+		return nil, fmt.Errorf("motorutil: invalid maximum speed: %v", maxSpeed)
+	}
+	if !(maxAccel > 0) || math.IsInf(maxAccel, 0) {
+		return nil, fmt.Errorf("motorutil: invalid maximum acceleration: %v", maxAccel)
+	}
+
+	p := &Profile{distance: math.Abs(distance), sign: 1, jerk: maxJerk}
+	if distance < 0 {
+		p.sign = -1
+	}
+	if p.distance == 0 {
+		return p, nil
+	}
+
+	p.peak = maxSpeed
+	if 2*accelDistance(maxSpeed, maxAccel, maxJerk) > p.distance {
+		// We cannot reach the maximum speed, so find the
+		// peak speed that covers the distance with only
+		// acceleration and deceleration phases.
+		lo, hi := 0.0, maxSpeed
+		for i := 0; i < 100; i++ {
+			mid := (lo + hi) / 2
+			if 2*accelDistance(mid, maxAccel, maxJerk) > p.distance {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		p.peak = lo
+	}
+	p.jerkTime, p.accelTime = accelTimes(p.peak, maxAccel, maxJerk)
+	p.cruiseTime = (p.distance - 2*accelDistance(p.peak, maxAccel, maxJerk)) / p.peak
+	if p.cruiseTime < 0 {
+		p.cruiseTime = 0
+	}
+	return p, nil
+}
+
+// accelTimes returns the duration of each jerk segment and the total duration
+// of an acceleration phase from rest to speed v with the given limits.
+func accelTimes(v, a, j float64) (jerkTime, accelTime float64) {
+	if v*j >= a*a {
+		jerkTime = a / j
+		return jerkTime, v/a + jerkTime
+	}
+	jerkTime = math.Sqrt(v / j)
+	return jerkTime, 2 * jerkTime
+}
+
+// accelDistance returns the distance traveled during an acceleration phase
+// from rest to speed v with the given limits.
+func accelDistance(v, a, j float64) float64 {
+	_, t := accelTimes(v, a, j)
+	return v * t / 2
+}
+
+// Duration returns the total duration of the Profile.
+func (p *Profile) Duration() time.Duration {
+	return time.Duration(p.duration() * float64(time.Second))
+}
+
+// Distance returns the signed distance traveled by the Profile.
+func (p *Profile) Distance() float64 { return p.sign * p.distance }
+
+// PeakSpeed returns the peak speed reached by the Profile.
+func (p *Profile) PeakSpeed() float64 { return p.peak }
+
+func (p *Profile) duration() float64 {
+	return 2*p.accelTime + p.cruiseTime
+}
+
+// At returns the signed position and speed of the Profile at time t after
+// the start of the motion. For times before the start, the position and
+// speed are zero and for times after the end the position is the distance
+// and the speed is zero.
+func (p *Profile) At(t time.Duration) (pos, speed float64) {
+	if p.distance == 0 {
+		return 0, 0
+	}
+	s := t.Seconds()
+	T := p.duration()
+	switch {
+	case s <= 0:
+		return 0, 0
+	case s >= T:
+		return p.sign * p.distance, 0
+	case s <= p.accelTime:
+		pos, speed = p.accel(s)
+	case s < p.accelTime+p.cruiseTime:
+		da, _ := p.accel(p.accelTime)
+		pos = da + p.peak*(s-p.accelTime)
+		speed = p.peak
+	default:
+		pos, speed = p.accel(T - s)
+		pos = p.distance - pos
+	}
+	return p.sign * pos, p.sign * speed
+}
+
+// accel returns the unsigned position and speed at time t into the
+// acceleration phase.
+func (p *Profile) accel(t float64) (pos, speed float64) {
+	tj, ta := p.jerkTime, p.accelTime
+	if tj == 0 {
+		// Constant acceleration.
+		a := p.peak / ta
+		return a * t * t / 2, a * t
+	}
+
+	j := p.jerk
+	a := j * tj
+	v1 := j * tj * tj / 2
+	p1 := j * tj * tj * tj / 6
+	switch {
+	case t <= tj:
+		return j * t * t * t / 6, j * t * t / 2
+	case t <= ta-tj:
+		dt := t - tj
+		return p1 + v1*dt + a*dt*dt/2, v1 + a*dt
+	default:
+		tau := ta - t
+		da := p.peak * ta / 2
+		return da - p.peak*tau + j*tau*tau*tau/6, p.peak - j*tau*tau/2
+	}
+}
+
+// FollowProfile moves the motor along the Profile starting from its current
+// position. Speed setpoints are streamed to the motor every period while it
+// runs in run-forever mode. The ev3dev tacho motor driver only applies a speed
+// setpoint when a run command is issued, so the run-forever command is sent
+// again after each setpoint. When the profile is complete, the motor is sent
+// to the final position with run-to-abs-pos and the hold stop action.
+//
+// If ctx is done before the profile is complete, the motor is stopped and
+// ctx.Err() is returned.
+func FollowProfile(ctx context.Context, m *ev3dev.TachoMotor, p *Profile, period time.Duration) error {
+	if m == nil {
+		return errors.New("motorutil: nil motor")
+	}
+	if period <= 0 {
+		return fmt.Errorf("motorutil: invalid setpoint period: %v", period)
+	}
+	start, err := m.Position()
+	if err != nil {
+		return err
+	}
+	target := start + round.Int(p.Distance())
+
+	err = m.SetSpeedSetpoint(0).Command("run-forever").Err()
+	if err != nil {
+		return err
+	}
+
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	begin := time.Now()
+	for {
+		t := time.Since(begin)
+		if t >= p.Duration() {
+			break
+		}
+		_, speed := p.At(t)
+		err = m.SetSpeedSetpoint(round.Int(speed)).Command("run-forever").Err()
+		if err != nil {
+			m.Command("stop").Err()
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			m.Command("stop").Err()
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+
+	final := round.Int(p.PeakSpeed() / 4)
+	if final < 1 {
+		final = 1
+	}
+	return m.SetStopAction("hold").
+		SetPositionSetpoint(target).
+		SetSpeedSetpoint(final).
+		Command("run-to-abs-pos").
+		Err()
+}
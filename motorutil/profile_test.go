@@ -0,0 +1,180 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+	"github.com/ev3go/ev3dev/sim"
+)
+
+var profileTests = []struct {
+	name     string
+	distance float64
+	maxSpeed float64
+	maxAccel float64
+	maxJerk  float64
+}{
+	{name: "trapezoid", distance: 1000, maxSpeed: 500, maxAccel: 1000, maxJerk: math.Inf(1)},
+	{name: "triangle", distance: 100, maxSpeed: 500, maxAccel: 1000, maxJerk: math.Inf(1)},
+	{name: "reverse trapezoid", distance: -1000, maxSpeed: 500, maxAccel: 1000, maxJerk: math.Inf(1)},
+	{name: "s-curve", distance: 1000, maxSpeed: 500, maxAccel: 1000, maxJerk: 5000},
+	{name: "s-curve no constant accel", distance: 1000, maxSpeed: 500, maxAccel: 1000, maxJerk: 1000},
+	{name: "short s-curve", distance: 10, maxSpeed: 500, maxAccel: 1000, maxJerk: 5000},
+	{name: "reverse s-curve", distance: -360, maxSpeed: 200, maxAccel: 400, maxJerk: 2000},
+}
+
+func TestProfile(t *testing.T) {
+	const (
+		steps = 10000
+		tol   = 1e-6
+	)
+	for _, test := range profileTests {
+		var (
+			p   *Profile
+			err error
+		)
+		if math.IsInf(test.maxJerk, 1) {
+			p, err = TrapezoidalProfile(test.distance, test.maxSpeed, test.maxAccel)
+		} else {
+			p, err = SCurveProfile(test.distance, test.maxSpeed, test.maxAccel, test.maxJerk)
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+			continue
+		}
+
+		pos, speed := p.At(0)
+		if pos != 0 || speed != 0 {
+			t.Errorf("unexpected start state for %s: pos=%v speed=%v", test.name, pos, speed)
+		}
+		pos, speed = p.At(p.Duration() + time.Second)
+		if pos != test.distance || speed != 0 {
+			t.Errorf("unexpected end state for %s: pos=%v speed=%v", test.name, pos, speed)
+		}
+
+		dt := p.Duration() / steps
+		h := dt.Seconds()
+		var lastPos, lastSpeed, lastAccel float64
+		for i := 1; i <= steps; i++ {
+			pos, speed := p.At(time.Duration(i) * dt)
+			if math.Abs(speed) > test.maxSpeed*(1+tol) {
+				t.Errorf("speed limit exceeded for %s at step %d: %v > %v", test.name, i, math.Abs(speed), test.maxSpeed)
+				break
+			}
+			if speed*test.distance < 0 {
+				t.Errorf("speed has wrong direction for %s at step %d: %v", test.name, i, speed)
+				break
+			}
+			// Check that position is the integral of speed.
+			mean := (speed + lastSpeed) / 2
+			if math.Abs((pos-lastPos)-mean*h) > 1e-3*math.Abs(test.distance)/steps+tol {
+				t.Errorf("position and speed inconsistent for %s at step %d", test.name, i)
+				break
+			}
+			accel := (speed - lastSpeed) / h
+			if math.Abs(accel) > test.maxAccel*(1+1e-3) {
+				t.Errorf("acceleration limit exceeded for %s at step %d: %v > %v", test.name, i, math.Abs(accel), test.maxAccel)
+				break
+			}
+			if !math.IsInf(test.maxJerk, 1) && i > 1 {
+				jerk := (accel - lastAccel) / h
+				if math.Abs(jerk) > test.maxJerk*(1+1e-2) {
+					t.Errorf("jerk limit exceeded for %s at step %d: %v > %v", test.name, i, math.Abs(jerk), test.maxJerk)
+					break
+				}
+			}
+			lastPos, lastSpeed, lastAccel = pos, speed, accel
+		}
+		if math.Abs(lastPos-test.distance) > tol {
+			t.Errorf("unexpected final position for %s: got:%v want:%v", test.name, lastPos, test.distance)
+		}
+	}
+}
+
+func TestProfileInvalid(t *testing.T) {
+	for _, args := range [][4]float64{
+		{100, 0, 100, 100},
+		{100, 100, -1, 100},
+		{math.NaN(), 100, 100, 100},
+		{100, 100, 100, 0},
+	} {
+		_, err := SCurveProfile(args[0], args[1], args[2], args[3])
+		if err == nil {
+			t.Errorf("expected error for profile parameters %v", args)
+		}
+	}
+}
+
+func TestFollowProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ev3dev-profile")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := sim.New(dir)
+	if err != nil {
+		t.Fatalf("unexpected error creating sim: %v", err)
+	}
+	ev3dev.SetPrefix(dir)
+	defer ev3dev.SetPrefix("")
+
+	sm, err := s.AddTachoMotor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error adding motor: %v", err)
+	}
+	m, err := ev3dev.TachoMotorFor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+	p, err := TrapezoidalProfile(720, 720, 2880)
+	if err != nil {
+		t.Fatalf("unexpected error creating profile: %v", err)
+	}
+
+	err = s.Start(2 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error starting sim: %v", err)
+	}
+	defer s.Close()
+
+	err = FollowProfile(context.Background(), m, p, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error following profile: %v", err)
+	}
+	// The motor must have tracked the profile's
+	// speeds rather than waiting for the final
+	// run-to-abs-pos command to move it.
+	if pos := sm.Position(); math.Abs(pos-720) > 36 {
+		t.Errorf("motor did not follow profile: got position %v at end of profile, want close to 720", pos)
+	}
+
+	end := time.Now().Add(3 * time.Second)
+	for {
+		stat, err := m.State()
+		if err != nil {
+			t.Fatalf("unexpected error reading state: %v", err)
+		}
+		if stat == ev3dev.Holding {
+			break
+		}
+		if time.Now().After(end) {
+			t.Fatalf("timed out waiting for motor to hold: state=%v", stat)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pos := sm.Position(); pos != 720 {
+		t.Errorf("unexpected final position: got:%v want:720", pos)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("unexpected sim error: %v", err)
+	}
+}
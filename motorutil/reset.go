@@ -6,8 +6,6 @@ package motorutil
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -123,17 +121,12 @@ func ResetAll() error {
 }
 
 func devicesIn(path string) ([]string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	return f.Readdirnames(0)
+	return ev3dev.CurrentBackend().List(path)
 }
 
 func portFor(path, base string) (string, error) {
 	path = filepath.Join(path, base, "address")
-	b, err := ioutil.ReadFile(path)
+	b, err := ev3dev.CurrentBackend().Read(path)
 	if err != nil {
 		return "", fmt.Errorf("motorutil: failed to read port: %v", err)
 	}
@@ -0,0 +1,272 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// StallEvent is a report of a motor stopped by a Watchdog.
+type StallEvent struct {
+	// Time is the time the motor
+	// was stopped.
+	Time time.Time
+
+	// Motor is the motor that was
+	// stopped, as passed to the
+	// Watchdog.
+	Motor ev3dev.StaterDevice
+
+	// State is the motor state that
+	// triggered the stop, and Duration
+	// is how long the triggering
+	// condition had persisted.
+	State    ev3dev.MotorState
+	Duration time.Duration
+
+	// Err is any error returned when
+	// stopping the motor.
+	Err error
+}
+
+// Watchdog monitors the state of a set of motors and stops any motor that
+// remains stalled or overloaded for longer than a configured duration.
+//
+// The Watchdog reads motor states using its own copies of the motor
+// handles, so the motors may be commanded while the Watchdog is running.
+type Watchdog struct {
+	motors  []ev3dev.StaterDevice
+	handles []ev3dev.StaterDevice
+	timers  []stallTimer
+
+	action string
+
+	events chan StallEvent
+
+	mu  sync.Mutex
+	err error
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewWatchdog returns a running Watchdog for the given motors. Motor states
+// are read every period and a motor is stopped with the given stop action
+// when any of the states in trigger have been continuously present for the
+// duration after. The trigger must be a non-empty combination of
+// ev3dev.Stalled and ev3dev.Overloaded.
+//
+// The motors must be *ev3dev.TachoMotor or *ev3dev.LinearActuator values
+// and the stop action must be available for each of them. DC motors do
+// not report stalled or overloaded states, so *ev3dev.DCMotor values are
+// rejected. The stop action of a stopped motor is restored to its
+// previous value once the stop command has been issued.
+func NewWatchdog(trigger ev3dev.MotorState, after time.Duration, action string, period time.Duration, motors ...ev3dev.StaterDevice) (*Watchdog, error) {
+	if trigger == 0 || trigger&^(ev3dev.Stalled|ev3dev.Overloaded) != 0 {
+		return nil, fmt.Errorf("motorutil: invalid watchdog trigger: %v", trigger)
+	}
+	if after < 0 {
+		return nil, fmt.Errorf("motorutil: invalid stall duration: %v", after)
+	}
+	if period <= 0 {
+		return nil, fmt.Errorf("motorutil: invalid sample period: %v", period)
+	}
+	if len(motors) == 0 {
+		return nil, errors.New("motorutil: no motors to watch")
+	}
+
+	w := &Watchdog{
+		motors:  append([]ev3dev.StaterDevice(nil), motors...),
+		handles: make([]ev3dev.StaterDevice, len(motors)),
+		timers:  make([]stallTimer, len(motors)),
+		action:  action,
+		events:  make(chan StallEvent, len(motors)),
+		done:    make(chan struct{}),
+	}
+	for i, m := range motors {
+		var (
+			avail []string
+			err   error
+		)
+		switch m := m.(type) {
+		case *ev3dev.TachoMotor:
+			if m == nil {
+				return nil, fmt.Errorf("motorutil: nil motor at index %d", i)
+			}
+			c := *m
+			w.handles[i] = &c
+			avail, err = m.StopActions()
+		case *ev3dev.DCMotor:
+			return nil, fmt.Errorf("motorutil: DC motor at index %d does not report stall or overload states", i)
+		case *ev3dev.LinearActuator:
+			if m == nil {
+				return nil, fmt.Errorf("motorutil: nil motor at index %d", i)
+			}
+			c := *m
+			w.handles[i] = &c
+			avail, err = m.StopActions()
+		default:
+			return nil, fmt.Errorf("motorutil: unsupported motor type at index %d: %T", i, m)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ok := false
+		for _, a := range avail {
+			if a == action {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("motorutil: stop action %q not available for %s (available:%q)", action, m, avail)
+		}
+		w.timers[i] = stallTimer{trigger: trigger, after: after}
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		tick := time.NewTicker(period)
+		defer tick.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-tick.C:
+				w.check()
+			}
+		}
+	}()
+	return w, nil
+}
+
+// check reads the state of each motor and stops those that have
+// exceeded the stall duration.
+func (w *Watchdog) check() {
+	for i, m := range w.handles {
+		stat, err := m.State()
+		now := time.Now()
+		if err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			continue
+		}
+		fire, d := w.timers[i].update(stat, now)
+		if !fire {
+			continue
+		}
+		e := StallEvent{
+			Motor:    w.motors[i],
+			State:    stat,
+			Duration: d,
+			Err:      stop(m, w.action),
+		}
+		e.Time = time.Now()
+		select {
+		case w.events <- e:
+		default:
+		}
+	}
+}
+
+// stop issues a stop command with the given stop action to m and then
+// restores the previous stop action of m.
+func stop(m ev3dev.StaterDevice, action string) error {
+	switch m := m.(type) {
+	case *ev3dev.TachoMotor:
+		prev, err := m.StopAction()
+		if err != nil {
+			return err
+		}
+		err = m.SetStopAction(action).Command("stop").Err()
+		if prev != action {
+			rerr := m.SetStopAction(prev).Err()
+			if err == nil {
+				err = rerr
+			}
+		}
+		return err
+	case *ev3dev.LinearActuator:
+		prev, err := m.StopAction()
+		if err != nil {
+			return err
+		}
+		err = m.SetStopAction(action).Command("stop").Err()
+		if prev != action {
+			rerr := m.SetStopAction(prev).Err()
+			if err == nil {
+				err = rerr
+			}
+		}
+		return err
+	default:
+		panic("motorutil: unsupported motor type")
+	}
+}
+
+// stallTimer tracks how long a triggering motor state has persisted.
+type stallTimer struct {
+	trigger ev3dev.MotorState
+	after   time.Duration
+
+	since time.Time
+	fired bool
+}
+
+// update updates the timer with the motor state read at time now. It
+// returns true if the motor should be stopped, and the duration the
+// triggering condition has persisted. A timer fires once for each
+// continuous period of the triggering condition.
+func (t *stallTimer) update(stat ev3dev.MotorState, now time.Time) (fire bool, d time.Duration) {
+	if stat&t.trigger == 0 {
+		t.since = time.Time{}
+		t.fired = false
+		return false, 0
+	}
+	if t.since.IsZero() {
+		t.since = now
+	}
+	d = now.Sub(t.since)
+	if t.fired || d < t.after {
+		return false, d
+	}
+	t.fired = true
+	return true, d
+}
+
+// Events returns the channel on which stop events are reported. Events
+// are dropped if the channel is not being received from.
+func (w *Watchdog) Events() <-chan StallEvent {
+	return w.events
+}
+
+// Err returns the last error that occurred while reading motor states and
+// clears it.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close stops the Watchdog monitoring and closes the events channel.
+// Close is safe to call more than once and concurrently.
+func (w *Watchdog) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		close(w.events)
+	})
+	return nil
+}
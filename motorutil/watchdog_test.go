@@ -0,0 +1,140 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package motorutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+func TestStallTimer(t *testing.T) {
+	const ms = time.Millisecond
+	steps := []struct {
+		at   time.Duration
+		stat ev3dev.MotorState
+
+		wantFire bool
+		wantDur  time.Duration
+	}{
+		{at: 0, stat: ev3dev.Running},
+		{at: 10 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantDur: 0},
+		{at: 50 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantDur: 40 * ms},
+		{at: 110 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantFire: true, wantDur: 100 * ms},
+		{at: 150 * ms, stat: ev3dev.Stalled, wantDur: 140 * ms},
+		{at: 160 * ms, stat: 0},
+		{at: 170 * ms, stat: ev3dev.Running | ev3dev.Overloaded},
+		{at: 200 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantDur: 0},
+		{at: 250 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantDur: 50 * ms},
+		{at: 300 * ms, stat: ev3dev.Running | ev3dev.Stalled, wantFire: true, wantDur: 100 * ms},
+	}
+
+	timer := stallTimer{trigger: ev3dev.Stalled, after: 100 * ms}
+	start := time.Now()
+	for i, step := range steps {
+		fire, d := timer.update(step.stat, start.Add(step.at))
+		if fire != step.wantFire || d != step.wantDur {
+			t.Errorf("unexpected result for step %d at %v with state %v: got:(%t, %v) want:(%t, %v)",
+				i, step.at, step.stat, fire, d, step.wantFire, step.wantDur)
+		}
+	}
+}
+
+func TestNewWatchdogErrors(t *testing.T) {
+	m := &ev3dev.TachoMotor{}
+	for _, test := range []struct {
+		trigger ev3dev.MotorState
+		after   time.Duration
+		period  time.Duration
+		motors  []ev3dev.StaterDevice
+	}{
+		{trigger: 0, after: time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{m}},
+		{trigger: ev3dev.Running, after: time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{m}},
+		{trigger: ev3dev.Stalled, after: -time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{m}},
+		{trigger: ev3dev.Stalled, after: time.Second, period: 0, motors: []ev3dev.StaterDevice{m}},
+		{trigger: ev3dev.Stalled, after: time.Second, period: time.Millisecond, motors: nil},
+		{trigger: ev3dev.Stalled, after: time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{(*ev3dev.TachoMotor)(nil)}},
+		{trigger: ev3dev.Stalled, after: time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{&ev3dev.ServoMotor{}}},
+		{trigger: ev3dev.Stalled, after: time.Second, period: time.Millisecond, motors: []ev3dev.StaterDevice{&ev3dev.DCMotor{}}},
+	} {
+		_, err := NewWatchdog(test.trigger, test.after, "brake", test.period, test.motors...)
+		if err == nil {
+			t.Errorf("expected error for trigger=%v after=%v period=%v motors=%v", test.trigger, test.after, test.period, test.motors)
+		}
+	}
+}
+
+func TestWatchdogStopsStalledMotor(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	m := b.addMotor(t, "motor0", "outA")
+	b.set(t, "motor0", "state", "running stalled")
+	b.Writes()
+
+	const after = 20 * time.Millisecond
+	w, err := NewWatchdog(ev3dev.Stalled, after, "brake", time.Millisecond, m)
+	if err != nil {
+		t.Fatalf("unexpected error creating watchdog: %v", err)
+	}
+	defer w.Close()
+
+	select {
+	case e := <-w.Events():
+		if e.Motor != m {
+			t.Errorf("unexpected motor in event: got:%v want:%v", e.Motor, m)
+		}
+		if e.State&ev3dev.Stalled == 0 {
+			t.Errorf("expected stalled state in event: got:%v", e.State)
+		}
+		if e.Duration < after {
+			t.Errorf("unexpected stall duration in event: got:%v want:>=%v", e.Duration, after)
+		}
+		if e.Err != nil {
+			t.Errorf("unexpected error stopping motor: %v", e.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for stall event")
+	}
+
+	want := []string{"motor0/stop_action=brake", "motor0/command=stop", "motor0/stop_action=coast"}
+	if got := b.Writes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected writes: got:%q want:%q", got, want)
+	}
+	if got := b.get(t, "motor0", "stop_action"); got != "coast" {
+		t.Errorf("stop action not restored: got:%q want:%q", got, "coast")
+	}
+}
+
+func TestWatchdogClose(t *testing.T) {
+	b, restore := useRecorder()
+	defer restore()
+
+	w, err := NewWatchdog(ev3dev.Stalled, time.Second, "brake", time.Millisecond, b.addMotor(t, "motor0", "outA"))
+	if err != nil {
+		t.Fatalf("unexpected error creating watchdog: %v", err)
+	}
+
+	// Concurrent calls to Close must not panic.
+	closed := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() { closed <- w.Close() }()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err = <-closed:
+			if err != nil {
+				t.Errorf("unexpected error closing watchdog: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for close")
+		}
+	}
+	if _, ok := <-w.Events(); ok {
+		t.Error("expected closed events channel")
+	}
+}
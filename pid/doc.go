@@ -0,0 +1,6 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pid provides a PID controller for closed-loop motor control.
+package pid
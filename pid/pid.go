@@ -0,0 +1,124 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pid
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+const (
+	// MinOutput and MaxOutput are the default output limits
+	// of a Controller, matching the duty cycle range of the
+	// ev3dev motor types.
+	MinOutput = -100
+	MaxOutput = 100
+)
+
+// Controller is a PID controller. The derivative term is computed from
+// the measurement rather than the error so that changes in the setpoint
+// do not cause derivative kick, and is optionally low-pass filtered.
+// The integral term is held when the output is saturated in the direction
+// of the error to prevent integral windup.
+//
+// A Controller is not safe for concurrent use.
+type Controller struct {
+	// Kp, Ki and Kd are the proportional,
+	// integral and derivative gains. The
+	// integral and derivative gains are
+	// per second.
+	Kp, Ki, Kd float64
+
+	// Setpoint is the target value of the
+	// controlled measurement.
+	Setpoint float64
+
+	// Min and Max are the output limits.
+	Min, Max float64
+
+	// Filter is the time constant of the
+	// first order low-pass filter applied
+	// to the derivative term. A zero Filter
+	// disables filtering.
+	Filter time.Duration
+
+	integral   float64
+	derivative float64
+	last       float64
+	primed     bool
+}
+
+// New returns a Controller with the given gains and the default output
+// limits, MinOutput and MaxOutput.
+func New(kp, ki, kd float64) *Controller {
+	return &Controller{Kp: kp, Ki: ki, Kd: kd, Min: MinOutput, Max: MaxOutput}
+}
+
+// Update returns the controller output for the measurement taken dt after
+// the previous measurement. The first call after creation or a Reset does
+// not contribute a derivative term.
+func (c *Controller) Update(measurement float64, dt time.Duration) (float64, error) {
+	if c.Min > c.Max {
+		return 0, fmt.Errorf("pid: invalid output limits: min=%v max=%v", c.Min, c.Max)
+	}
+	if dt <= 0 {
+		return 0, fmt.Errorf("pid: invalid time step: %v", dt)
+	}
+	h := dt.Seconds()
+	e := c.Setpoint - measurement
+
+	if c.primed && c.Kd != 0 {
+		d := -(measurement - c.last) / h
+		if c.Filter > 0 {
+			alpha := h / (c.Filter.Seconds() + h)
+			c.derivative += alpha * (d - c.derivative)
+		} else {
+			c.derivative = d
+		}
+	}
+	c.last = measurement
+	c.primed = true
+
+	integral := c.integral + c.Ki*e*h
+	out := c.Kp*e + integral + c.Kd*c.derivative
+	switch {
+	case out > c.Max:
+		out = c.Max
+		if e < 0 {
+			c.integral = integral
+		}
+	case out < c.Min:
+		out = c.Min
+		if e > 0 {
+			c.integral = integral
+		}
+	default:
+		c.integral = integral
+	}
+	return out, nil
+}
+
+// Reset clears the integral and derivative state of the Controller.
+func (c *Controller) Reset() {
+	c.integral = 0
+	c.derivative = 0
+	c.last = 0
+	c.primed = false
+}
+
+// clamp returns the output value rounded to the nearest integer and
+// limited to the range [min, max].
+func clamp(v float64, min, max int) int {
+	i := round.Int(v)
+	switch {
+	case i < min:
+		return min
+	case i > max:
+		return max
+	}
+	return i
+}
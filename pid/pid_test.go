@@ -0,0 +1,196 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pid
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProportional(t *testing.T) {
+	c := New(2, 0, 0)
+	c.Setpoint = 10
+	for _, test := range []struct {
+		measurement float64
+		want        float64
+	}{
+		{measurement: 0, want: 20},
+		{measurement: 5, want: 10},
+		{measurement: 10, want: 0},
+		{measurement: 15, want: -10},
+		{measurement: 100, want: MinOutput},
+		{measurement: -100, want: MaxOutput},
+	} {
+		got, err := c.Update(test.measurement, time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("unexpected output for measurement %v: got:%v want:%v", test.measurement, got, test.want)
+		}
+	}
+}
+
+func TestIntegral(t *testing.T) {
+	c := New(0, 1, 0)
+	c.Setpoint = 1
+	var got float64
+	for i := 0; i < 10; i++ {
+		var err error
+		got, err = c.Update(0, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if math.Abs(got-1) > 1e-9 {
+		t.Errorf("unexpected integral output: got:%v want:1", got)
+	}
+}
+
+func TestAntiWindup(t *testing.T) {
+	c := New(0, 10, 0)
+	c.Setpoint = 100
+	for i := 0; i < 1000; i++ {
+		got, err := c.Update(0, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != MaxOutput {
+			t.Fatalf("unexpected output during saturation at step %d: got:%v want:%v", i, got, MaxOutput)
+		}
+	}
+	// With windup the integral would be far above the
+	// limit and a reversed error would not unsaturate
+	// the output for a long time.
+	c.Setpoint = 0
+	got, err := c.Update(100, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got >= MaxOutput {
+		t.Errorf("output did not recover from saturation: got:%v", got)
+	}
+}
+
+func TestDerivative(t *testing.T) {
+	c := New(0, 0, 1)
+	got, err := c.Update(0, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 0 {
+		t.Errorf("unexpected derivative output for first update: got:%v want:0", got)
+	}
+
+	// A setpoint change does not cause derivative kick.
+	c.Setpoint = 50
+	got, err = c.Update(0, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 0 {
+		t.Errorf("unexpected derivative output for setpoint change: got:%v want:0", got)
+	}
+
+	got, err = c.Update(10, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != -10 {
+		t.Errorf("unexpected derivative output for measurement change: got:%v want:-10", got)
+	}
+}
+
+func TestDerivativeFilter(t *testing.T) {
+	c := New(0, 0, 1)
+	c.Filter = time.Second
+	c.Update(0, time.Second)
+	got, err := c.Update(10, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != -5 {
+		t.Errorf("unexpected filtered derivative output: got:%v want:-5", got)
+	}
+	c.Reset()
+	got, err = c.Update(20, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 0 {
+		t.Errorf("unexpected derivative output after reset: got:%v want:0", got)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	c := New(1, 1, 1)
+	_, err := c.Update(0, 0)
+	if err == nil {
+		t.Error("expected error for zero time step")
+	}
+	c.Min, c.Max = 1, -1
+	_, err = c.Update(0, time.Second)
+	if err == nil {
+		t.Error("expected error for invalid output limits")
+	}
+}
+
+var clampTests = []struct {
+	v    float64
+	want int
+}{
+	{v: 0, want: 0},
+	{v: 49.5, want: 50},
+	{v: -49.5, want: -50},
+	{v: 100.4, want: 100},
+	{v: 1000, want: 100},
+	{v: -1000, want: -100},
+}
+
+func TestClamp(t *testing.T) {
+	for _, test := range clampTests {
+		got := clamp(test.v, MinOutput, MaxOutput)
+		if got != test.want {
+			t.Errorf("unexpected clamped value for %v: got:%d want:%d", test.v, got, test.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	// Simulate a first order plant driven by the controller.
+	var (
+		plant float64
+		n     int
+	)
+	c := New(0.5, 0, 0)
+	c.Setpoint = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := func() (float64, error) { return plant, nil }
+	out := func(u float64) error {
+		plant += 0.1 * u
+		n++
+		if n == 200 {
+			cancel()
+		}
+		return nil
+	}
+	err := Run(ctx, c, in, out, time.Millisecond)
+	if err != context.Canceled {
+		t.Errorf("unexpected error: got:%v want:%v", err, context.Canceled)
+	}
+	if math.Abs(plant-c.Setpoint) > 0.1 {
+		t.Errorf("plant did not converge to setpoint: got:%v want:%v", plant, c.Setpoint)
+	}
+
+	errFail := errors.New("input failure")
+	err = Run(context.Background(), c, func() (float64, error) { return 0, errFail }, out, time.Millisecond)
+	if err != errFail {
+		t.Errorf("unexpected error: got:%v want:%v", err, errFail)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Input is a source of controller measurements.
+type Input func() (float64, error)
+
+// Output is a sink for controller outputs.
+type Output func(float64) error
+
+// Run runs the Controller at a fixed rate, reading a measurement from in
+// and writing the controller output to out every period, until ctx is done
+// or an error occurs. The time step passed to the Controller is the measured
+// time between successive measurements. Run returns ctx.Err() when ctx is
+// done.
+func Run(ctx context.Context, c *Controller, in Input, out Output, period time.Duration) error {
+	if c == nil {
+		return errors.New("pid: nil controller")
+	}
+	if period <= 0 {
+		return fmt.Errorf("pid: invalid period: %v", period)
+	}
+
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	last := time.Now()
+	dt := period
+	for {
+		v, err := in()
+		if err != nil {
+			return err
+		}
+		u, err := c.Update(v, dt)
+		if err != nil {
+			return err
+		}
+		err = out(u)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			dt = now.Sub(last)
+			if dt <= 0 {
+				dt = period
+			}
+			last = now
+		}
+	}
+}
+
+// SensorInput returns an Input that reads the nth scaled value of the
+// sensor in its current mode.
+func SensorInput(s *ev3dev.Sensor, n int) Input {
+	return func() (float64, error) {
+		values, _, err := s.ScaledValues()
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 || n >= len(values) {
+			return 0, fmt.Errorf("pid: value index out of range for %s: %d (%d values)", s, n, len(values))
+		}
+		return values[n], nil
+	}
+}
+
+// TachoMotorDutyCycle returns an Output that writes the controller output
+// to the duty cycle setpoint of the motor, rounded to the nearest integer
+// and limited to the range [MinOutput, MaxOutput]. The motor must be running
+// in run-direct mode for the setpoint to take effect.
+func TachoMotorDutyCycle(m *ev3dev.TachoMotor) Output {
+	return func(u float64) error {
+		return m.SetDutyCycleSetpoint(clamp(u, MinOutput, MaxOutput)).Err()
+	}
+}
+
+// DCMotorDutyCycle returns an Output that writes the controller output
+// to the duty cycle setpoint of the motor, rounded to the nearest integer
+// and limited to the range [MinOutput, MaxOutput]. The motor must be running
+// in run-direct mode for the setpoint to take effect.
+func DCMotorDutyCycle(m *ev3dev.DCMotor) Output {
+	return func(u float64) error {
+		return m.SetDutyCycleSetpoint(clamp(u, MinOutput, MaxOutput)).Err()
+	}
+}
+
+// LinearActuatorDutyCycle returns an Output that writes the controller
+// output to the duty cycle setpoint of the actuator, rounded to the nearest
+// integer and limited to the range [MinOutput, MaxOutput]. The actuator must
+// be running in run-direct mode for the setpoint to take effect.
+func LinearActuatorDutyCycle(m *ev3dev.LinearActuator) Output {
+	return func(u float64) error {
+		return m.SetDutyCycleSetpoint(clamp(u, MinOutput, MaxOutput)).Err()
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"image/png"
+	"net"
+	"sync"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Client is a connection to a remote Server. Client is an ev3dev.Backend
+// and is safe for concurrent use.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+	err  error
+}
+
+var _ ev3dev.Backend = (*Client)(nil)
+
+// Dial connects to the Server at the TCP network address addr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client using conn to communicate with a Server.
+func NewClient(conn net.Conn) *Client {
+	w := bufio.NewWriter(conn)
+	return &Client{
+		conn: conn,
+		w:    w,
+		enc:  gob.NewEncoder(w),
+		dec:  gob.NewDecoder(bufio.NewReader(conn)),
+	}
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// do sends req to the server and returns the server's response.
+// Once a connection error has occurred, all subsequent requests
+// fail with that error.
+func (c *Client) do(req request) (response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return response{}, c.err
+	}
+
+	var resp response
+	err := c.enc.Encode(req)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if err == nil {
+		err = c.dec.Decode(&resp)
+	}
+	if err != nil {
+		c.err = fmt.Errorf("remote: connection failed: %v", err)
+		return response{}, c.err
+	}
+	return resp, resp.Err.err()
+}
+
+// Read returns the contents of the remote attribute file at path.
+func (c *Client) Read(path string) ([]byte, error) {
+	resp, err := c.do(request{Op: opRead, Path: path})
+	return resp.Data, err
+}
+
+// Write writes data to the remote attribute file at path.
+func (c *Client) Write(path string, data []byte) error {
+	_, err := c.do(request{Op: opWrite, Path: path, Data: data})
+	return err
+}
+
+// List returns the names of the entries in the remote directory at path.
+func (c *Client) List(path string) ([]string, error) {
+	resp, err := c.do(request{Op: opList, Path: path})
+	return resp.Names, err
+}
+
+// Buttons returns the set of buttons currently pressed on the remote brick.
+func (c *Client) Buttons() (ev3dev.Button, error) {
+	resp, err := c.do(request{Op: opButtons})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Data) != 1 {
+		return 0, fmt.Errorf("remote: invalid buttons response length: %d", len(resp.Data))
+	}
+	return ev3dev.Button(resp.Data[0]), nil
+}
+
+// LCD returns an image of the current contents of the remote LCD.
+func (c *Client) LCD() (image.Image, error) {
+	resp, err := c.do(request{Op: opLCDRead})
+	if err != nil {
+		return nil, err
+	}
+	img, err := png.Decode(bytes.NewReader(resp.Data))
+	if err != nil {
+		return nil, fmt.Errorf("remote: failed to decode LCD image: %v", err)
+	}
+	return img, nil
+}
+
+// DrawLCD draws img onto the remote LCD. The image is aligned with the
+// top-left corner of the LCD.
+func (c *Client) DrawLCD(img image.Image) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return fmt.Errorf("remote: failed to encode LCD image: %v", err)
+	}
+	_, err = c.do(request{Op: opLCDWrite, Data: buf.Bytes()})
+	return err
+}
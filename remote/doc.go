@@ -0,0 +1,34 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package remote provides access to the devices of a remote ev3dev brick
+// over TCP.
+//
+// A Server runs on the brick and serves the ev3dev sysfs device classes,
+// the buttons and the LCD frame buffer. A Client is an ev3dev.Backend, so
+// programs on a workstation can use the ev3dev package transparently
+// against the remote devices by setting the client as the backend:
+//
+//	c, err := remote.Dial("ev3dev.local:4242")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//	ev3dev.SetBackend(c)
+//
+//	m, err := ev3dev.TachoMotorFor("outA", "lego-ev3-l-motor")
+//	...
+//
+// On the brick:
+//
+//	s := &remote.Server{LCD: ev3.LCD}
+//	err := ev3.LCD.Init(true)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	log.Fatal(s.ListenAndServe(":4242"))
+//
+// The protocol is not authenticated or encrypted and should only be used
+// on trusted networks.
+package remote
@@ -0,0 +1,91 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Request operations.
+const (
+	opRead     = "read"
+	opWrite    = "write"
+	opList     = "list"
+	opButtons  = "buttons"
+	opLCDRead  = "lcd-read"
+	opLCDWrite = "lcd-write"
+)
+
+// request is a client request. Requests and responses are gob encoded
+// and each request is answered by exactly one response.
+type request struct {
+	Op   string
+	Path string
+	Data []byte
+}
+
+// response is a server response to a request.
+type response struct {
+	Data  []byte
+	Names []string
+	Err   *wireError
+}
+
+// wireError is an error transmitted between server and client.
+type wireError struct {
+	Op    string
+	Path  string
+	Errno syscall.Errno
+	Msg   string
+}
+
+// wireErrorFrom returns a wireError for err, preserving path errors and
+// their errno values where possible so that os.IsNotExist and similar
+// functions work on the client.
+func wireErrorFrom(err error) *wireError {
+	if err == nil {
+		return nil
+	}
+	e := &wireError{Msg: err.Error()}
+	if perr, ok := err.(*os.PathError); ok {
+		e.Op = perr.Op
+		e.Path = perr.Path
+		e.Msg = perr.Err.Error()
+		err = perr.Err
+	}
+	switch {
+	case isErrno(err):
+		e.Errno = err.(syscall.Errno)
+	case os.IsNotExist(err):
+		e.Errno = syscall.ENOENT
+	case os.IsPermission(err):
+		e.Errno = syscall.EACCES
+	}
+	return e
+}
+
+func isErrno(err error) bool {
+	_, ok := err.(syscall.Errno)
+	return ok
+}
+
+// err returns the error described by e.
+func (e *wireError) err() error {
+	if e == nil {
+		return nil
+	}
+	var err error
+	if e.Errno != 0 {
+		err = e.Errno
+	} else {
+		err = errors.New(e.Msg)
+	}
+	if e.Op == "" {
+		return err
+	}
+	return &os.PathError{Op: e.Op, Path: e.Path, Err: err}
+}
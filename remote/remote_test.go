@@ -0,0 +1,283 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ev3go/ev3dev"
+)
+
+func newTestServer(t *testing.T, s *Server) (*Client, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		s.Close()
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	return c, func() {
+		c.Close()
+		s.Close()
+		if err := <-done; err != errServerClosed {
+			t.Errorf("unexpected error from Serve: %v", err)
+		}
+	}
+}
+
+func TestRemoteDevices(t *testing.T) {
+	mem := ev3dev.NewMemoryBackend()
+	for path, attrs := range map[string]map[string]string{
+		filepath.Join(ev3dev.TachoMotorPath, "motor0"): {
+			"address":      "outA",
+			"driver_name":  "lego-ev3-l-motor",
+			"commands":     "run-forever stop reset",
+			"position":     "90",
+			"speed_pid/Kp": "1000",
+			"hold_pid/Ki":  "60",
+		},
+		filepath.Join(ev3dev.SensorPath, "sensor0"): {
+			"address":     "in1",
+			"driver_name": "lego-ev3-touch",
+			"num_values":  "1",
+			"decimals":    "0",
+			"value0":      "1",
+		},
+		filepath.Join(ev3dev.LEDPath, "led0:green:brick-status"): {
+			"brightness":     "0",
+			"max_brightness": "255",
+		},
+		filepath.Join(ev3dev.PowerSupplyPath, "lego-ev3-battery"): {
+			"voltage_now": "7500000",
+		},
+	} {
+		for attr, val := range attrs {
+			err := mem.Write(filepath.Join(path, attr), []byte(val+"\n"))
+			if err != nil {
+				t.Fatalf("unexpected error writing %s: %v", attr, err)
+			}
+		}
+	}
+
+	c, done := newTestServer(t, &Server{Backend: mem})
+	defer done()
+	prev := ev3dev.SetBackend(c)
+	defer ev3dev.SetBackend(prev)
+
+	m, err := ev3dev.TachoMotorFor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+	pos, err := m.Position()
+	if err != nil || pos != 90 {
+		t.Errorf("unexpected position: got:%d want:90 (err=%v)", pos, err)
+	}
+	err = m.Command("run-forever").Err()
+	if err != nil {
+		t.Errorf("unexpected error commanding motor: %v", err)
+	}
+	got, err := mem.Read(filepath.Join(ev3dev.TachoMotorPath, "motor0", "command"))
+	if err != nil || string(got) != "run-forever" {
+		t.Errorf("unexpected command on server: got:%q want:%q (err=%v)", got, "run-forever", err)
+	}
+	kp, err := m.SpeedPIDKp()
+	if err != nil || kp != 1000 {
+		t.Errorf("unexpected speed PID Kp: got:%d want:1000 (err=%v)", kp, err)
+	}
+	err = m.SetSpeedPIDKp(1500).SetHoldPIDKi(70).Err()
+	if err != nil {
+		t.Errorf("unexpected error setting PID gains: %v", err)
+	}
+	got, err = mem.Read(filepath.Join(ev3dev.TachoMotorPath, "motor0", "speed_pid", "Kp"))
+	if err != nil || string(got) != "1500" {
+		t.Errorf("unexpected speed PID Kp on server: got:%q want:%q (err=%v)", got, "1500", err)
+	}
+	ki, err := m.HoldPIDKi()
+	if err != nil || ki != 70 {
+		t.Errorf("unexpected hold PID Ki: got:%d want:70 (err=%v)", ki, err)
+	}
+	_, err = ev3dev.TachoMotorFor("outB", "lego-ev3-l-motor")
+	if err == nil {
+		t.Error("expected error getting missing motor")
+	}
+
+	s, err := ev3dev.SensorFor("in1", "lego-ev3-touch")
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor: %v", err)
+	}
+	val, err := s.Value(0)
+	if err != nil || val != "1" {
+		t.Errorf("unexpected sensor value: got:%q want:%q (err=%v)", val, "1", err)
+	}
+
+	l := &ev3dev.LED{Name: ledName("led0:green:brick-status")}
+	err = l.SetBrightness(255).Err()
+	if err != nil {
+		t.Errorf("unexpected error setting LED brightness: %v", err)
+	}
+	bright, err := l.Brightness()
+	if err != nil || bright != 255 {
+		t.Errorf("unexpected LED brightness: got:%d want:255 (err=%v)", bright, err)
+	}
+
+	v, err := ev3dev.PowerSupply("").Voltage()
+	if err != nil || math.Abs(v-7.5) > 1e-9 {
+		t.Errorf("unexpected voltage: got:%v want:7.5 (err=%v)", v, err)
+	}
+
+	_, err = c.Read(filepath.Join(ev3dev.TachoMotorPath, "motor0", "missing"))
+	if !os.IsNotExist(err) {
+		t.Errorf("unexpected error reading missing attribute: got:%v want not exist error", err)
+	}
+	for _, path := range []string{
+		"/etc/passwd",
+		ev3dev.TachoMotorPath + "/../../../etc/passwd",
+		ev3dev.TachoMotorPath + "-fake/motor0",
+	} {
+		_, err = c.Read(path)
+		if !os.IsPermission(err) {
+			t.Errorf("unexpected error reading %s: got:%v want permission error", path, err)
+		}
+	}
+}
+
+func TestRemotePathEscape(t *testing.T) {
+	root, err := ioutil.TempDir("", "ev3dev-remote")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	// Lay out a sysfs-like tree where the class
+	// entry links into a devices directory and
+	// the device has links leading out of it.
+	dev := filepath.Join(root, "sys", "devices", "platform", "port0")
+	class := filepath.Join(root, ev3dev.LegoPortPath)
+	for _, dir := range []string{dev, class, filepath.Join(dev, "speed_pid"), filepath.Join(dev, "other")} {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+	}
+	for name, data := range map[string]string{
+		filepath.Join(dev, "address"):          "outA\n",
+		filepath.Join(dev, "speed_pid", "Kp"):  "1000\n",
+		filepath.Join(dev, "other", "file"):    "other\n",
+		filepath.Join(root, "sys", "secret"):   "secret\n",
+		filepath.Join(dev, "..", "driver_ctl"): "unbind\n",
+	} {
+		err = ioutil.WriteFile(name, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	for link, target := range map[string]string{
+		filepath.Join(class, "port0"):         dev,
+		filepath.Join(dev, "device"):          filepath.Join(root, "sys", "devices", "platform"),
+		filepath.Join(dev, "subsystem"):       filepath.Join(root, "sys"),
+		filepath.Join(dev, "escape"):          filepath.Join(root, "sys", "secret"),
+		filepath.Join(dev, "address_alt"):     filepath.Join(dev, "address"),
+		filepath.Join(dev, "speed_pid", "Ki"): filepath.Join(root, "sys", "secret"),
+		filepath.Join(dev, "speed_pid", "Kd"): filepath.Join(dev, "address"),
+	} {
+		err = os.Symlink(target, link)
+		if err != nil {
+			t.Fatalf("failed to create symlink: %v", err)
+		}
+	}
+
+	c, done := newTestServer(t, &Server{Root: root})
+	defer done()
+
+	port := filepath.Join(ev3dev.LegoPortPath, "port0")
+	for _, path := range []string{
+		filepath.Join(port, "address"),
+		filepath.Join(port, "address_alt"),
+	} {
+		got, err := c.Read(path)
+		if err != nil || string(got) != "outA\n" {
+			t.Errorf("unexpected result reading %s: got:%q want:%q (err=%v)", path, got, "outA\n", err)
+		}
+	}
+	kp := filepath.Join(port, "speed_pid", "Kp")
+	got, err := c.Read(kp)
+	if err != nil || string(got) != "1000\n" {
+		t.Errorf("unexpected result reading %s: got:%q want:%q (err=%v)", kp, got, "1000\n", err)
+	}
+	names, err := c.List(port)
+	if err != nil || len(names) == 0 {
+		t.Errorf("unexpected result listing %s: got:%q (err=%v)", port, names, err)
+	}
+	for _, path := range []string{
+		filepath.Join(port, "escape"),
+		filepath.Join(port, "device"),
+		filepath.Join(port, "device", "driver_ctl"),
+		filepath.Join(port, "subsystem", "secret"),
+		filepath.Join(port, "..", "..", "secret"),
+		filepath.Join(port, "speed_pid", "Ki"),
+		filepath.Join(port, "speed_pid", "Kd"),
+		filepath.Join(port, "speed_pid", "Kp", "x"),
+		filepath.Join(port, "other", "file"),
+	} {
+		_, err = c.Read(path)
+		if !os.IsPermission(err) {
+			t.Errorf("unexpected error reading %s: got:%v want permission error", path, err)
+		}
+		err = c.Write(path, []byte("unbind"))
+		if !os.IsPermission(err) {
+			t.Errorf("unexpected error writing %s: got:%v want permission error", path, err)
+		}
+	}
+}
+
+func TestRemoteButtonsAndLCD(t *testing.T) {
+	lcd := image.NewGray(image.Rect(0, 0, 178, 128))
+	c, done := newTestServer(t, &Server{
+		Buttons: func() (ev3dev.Button, error) { return ev3dev.Left | ev3dev.Middle, nil },
+		LCD:     lcd,
+	})
+	defer done()
+
+	pressed, err := c.Buttons()
+	if err != nil || pressed != ev3dev.Left|ev3dev.Middle {
+		t.Errorf("unexpected buttons: got:%v want:%v (err=%v)", pressed, ev3dev.Left|ev3dev.Middle, err)
+	}
+
+	img := image.NewGray(lcd.Bounds())
+	img.SetGray(10, 20, color.Gray{Y: 0xff})
+	err = c.DrawLCD(img)
+	if err != nil {
+		t.Fatalf("unexpected error drawing LCD: %v", err)
+	}
+	if !reflect.DeepEqual(lcd.Pix, img.Pix) {
+		t.Error("unexpected LCD contents on server")
+	}
+	got, err := c.LCD()
+	if err != nil {
+		t.Fatalf("unexpected error reading LCD: %v", err)
+	}
+	if got.Bounds() != lcd.Bounds() {
+		t.Errorf("unexpected LCD bounds: got:%v want:%v", got.Bounds(), lcd.Bounds())
+	}
+	if y := color.GrayModel.Convert(got.At(10, 20)).(color.Gray).Y; y != 0xff {
+		t.Errorf("unexpected LCD pixel value: got:%#x want:0xff", y)
+	}
+}
+
+type ledName string
+
+func (n ledName) String() string { return string(n) }
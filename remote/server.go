@@ -0,0 +1,343 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image/draw"
+	"image/png"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// errServerClosed is returned by Serve after Close has been called.
+var errServerClosed = errors.New("remote: server closed")
+
+// classPaths are the sysfs device class paths served by a Server.
+var classPaths = []string{
+	ev3dev.LegoPortPath,
+	ev3dev.SensorPath,
+	ev3dev.TachoMotorPath,
+	ev3dev.ServoMotorPath,
+	ev3dev.DCMotorPath,
+	ev3dev.LEDPath,
+	ev3dev.PowerSupplyPath,
+}
+
+// Server serves ev3dev device attributes, buttons and an LCD to remote
+// clients. The zero value serves the device attributes and buttons of the
+// local brick.
+type Server struct {
+	// Root is prepended to the paths
+	// of requested attributes. It is
+	// typically empty, but may be set
+	// to the root of a sim.Sim.
+	Root string
+
+	// Backend is used to access the
+	// device attributes. If Backend
+	// is nil, the ev3dev.SysfsBackend
+	// is used.
+	Backend ev3dev.Backend
+
+	// Buttons returns the currently
+	// pressed buttons. If Buttons is
+	// nil an ev3dev.ButtonPoller is
+	// used.
+	Buttons func() (ev3dev.Button, error)
+
+	// LCD is the frame buffer served
+	// to clients. If LCD is nil, LCD
+	// requests fail. If LCD is an
+	// ev3dev.FrameBuffer, it must be
+	// initialised before serving.
+	LCD draw.Image
+
+	pollMu sync.Mutex
+	poller ev3dev.ButtonPoller
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// ListenAndServe listens on the TCP network address addr and then calls
+// Serve to handle requests on incoming connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts incoming connections on the listener l, handling requests
+// on each connection in a new goroutine. Serve always returns a non-nil
+// error and closes l.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return errServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return errServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes all listeners and connections being served and waits for
+// the connection handlers to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if lerr := l.Close(); err == nil {
+			err = lerr
+		}
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn handles requests on conn until the connection is closed or
+// a protocol error occurs.
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	w := bufio.NewWriter(conn)
+	dec := gob.NewDecoder(bufio.NewReader(conn))
+	enc := gob.NewEncoder(w)
+	for {
+		var req request
+		err := dec.Decode(&req)
+		if err != nil {
+			return
+		}
+		resp := s.handle(req)
+		err = enc.Encode(resp)
+		if err != nil {
+			return
+		}
+		err = w.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle performs the request and returns its response.
+func (s *Server) handle(req request) response {
+	var (
+		resp response
+		err  error
+	)
+	switch req.Op {
+	case opRead, opWrite, opList:
+		var path string
+		path, err = s.path(req.Path)
+		if err != nil {
+			break
+		}
+		b := s.Backend
+		if b == nil {
+			b = ev3dev.SysfsBackend{}
+		}
+		switch req.Op {
+		case opRead:
+			resp.Data, err = b.Read(path)
+		case opWrite:
+			err = b.Write(path, req.Data)
+		case opList:
+			resp.Names, err = b.List(path)
+		}
+		if perr, ok := err.(*os.PathError); ok {
+			// Do not leak the server root.
+			perr.Path = req.Path
+		}
+	case opButtons:
+		var pressed ev3dev.Button
+		pressed, err = s.buttons()
+		resp.Data = []byte{byte(pressed)}
+	case opLCDRead:
+		resp.Data, err = s.readLCD()
+	case opLCDWrite:
+		err = s.writeLCD(req.Data)
+	default:
+		err = fmt.Errorf("remote: unknown operation %q", req.Op)
+	}
+	resp.Err = wireErrorFrom(err)
+	return resp
+}
+
+// linkAttrs are the sysfs device directory entries that link out of
+// the device directory.
+var linkAttrs = map[string]bool{
+	"device":    true,
+	"driver":    true,
+	"subsystem": true,
+}
+
+// attrDirs are the sysfs device directory entries that are
+// directories of attributes.
+var attrDirs = map[string]bool{
+	"hold_pid":  true,
+	"speed_pid": true,
+}
+
+// path returns the local path for the requested path p. Only the served
+// device class directories, the device directories within them and the
+// attributes of those devices, including the attributes in the device's
+// PID directories, are allowed. When the Backend is the file system,
+// symbolic links are resolved and an attribute must resolve to a file
+// within its device directory or the PID directory holding it.
+func (s *Server) path(p string) (string, error) {
+	denied := &os.PathError{Op: "open", Path: p, Err: syscall.EACCES}
+
+	clean := filepath.Clean(p)
+	var class, rel string
+	for _, c := range classPaths {
+		if clean == c {
+			return filepath.Join(s.Root, clean), nil
+		}
+		if strings.HasPrefix(clean, c+"/") {
+			class, rel = c, clean[len(c)+1:]
+			break
+		}
+	}
+	if rel == "" {
+		return "", denied
+	}
+	path := filepath.Join(s.Root, clean)
+	parts := strings.Split(rel, "/")
+	switch {
+	case len(parts) == 1:
+		return path, nil
+	case linkAttrs[parts[1]]:
+		return "", denied
+	case len(parts) == 3 && attrDirs[parts[1]]:
+	case len(parts) > 2:
+		return "", denied
+	}
+	if !s.isFileSystem() {
+		return path, nil
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Join(s.Root, class, parts[0]))
+	if err != nil {
+		return "", &os.PathError{Op: "open", Path: p, Err: underlying(err)}
+	}
+	if len(parts) == 3 {
+		dir = filepath.Join(dir, parts[1])
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", &os.PathError{Op: "open", Path: p, Err: underlying(err)}
+	}
+	if filepath.Dir(target) != dir {
+		return "", denied
+	}
+	return target, nil
+}
+
+// isFileSystem returns whether the Backend of s accesses the file system.
+func (s *Server) isFileSystem() bool {
+	return s.Backend == nil || ev3dev.IsFileBackend(s.Backend)
+}
+
+// underlying returns the error underlying an *os.PathError.
+func underlying(err error) error {
+	if perr, ok := err.(*os.PathError); ok {
+		return perr.Err
+	}
+	return err
+}
+
+func (s *Server) buttons() (ev3dev.Button, error) {
+	if s.Buttons != nil {
+		return s.Buttons()
+	}
+	s.pollMu.Lock()
+	defer s.pollMu.Unlock()
+	return s.poller.Poll()
+}
+
+func (s *Server) readLCD() ([]byte, error) {
+	if s.LCD == nil {
+		return nil, errors.New("remote: no LCD")
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, s.LCD)
+	if err != nil {
+		return nil, fmt.Errorf("remote: failed to encode LCD image: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Server) writeLCD(data []byte) error {
+	if s.LCD == nil {
+		return errors.New("remote: no LCD")
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("remote: failed to decode LCD image: %v", err)
+	}
+	draw.Draw(s.LCD, s.LCD.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nil
+}
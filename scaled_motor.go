@@ -0,0 +1,273 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+// ScaledTachoMotor wraps a TachoMotor to provide position and speed
+// setpoints and readings in physical units. Angular positions are in
+// degrees or rotations, and speeds are in degrees per second, rotations
+// per minute or as a percentage of the motor's maximum speed.
+//
+// Conversions use the count_per_rot and max_speed attributes read when
+// the ScaledTachoMotor is created. The result and action method semantics
+// of the embedded TachoMotor apply to the ScaledTachoMotor methods.
+type ScaledTachoMotor struct {
+	*TachoMotor
+
+	countPerRot int
+	maxSpeed    int
+}
+
+// NewScaledTachoMotor returns a ScaledTachoMotor wrapping m.
+func NewScaledTachoMotor(m *TachoMotor) (*ScaledTachoMotor, error) {
+	if m == nil {
+		return nil, errors.New("ev3dev: nil tacho motor")
+	}
+	cpr, err := m.CountPerRot()
+	if err != nil {
+		return nil, err
+	}
+	if cpr <= 0 {
+		return nil, fmt.Errorf("ev3dev: invalid count per rotation for %s: %d", m, cpr)
+	}
+	max, err := m.MaxSpeed()
+	if err != nil {
+		return nil, err
+	}
+	return &ScaledTachoMotor{TachoMotor: m, countPerRot: cpr, maxSpeed: max}, nil
+}
+
+// countsPerDegree returns the number of tacho counts in one degree.
+func (m *ScaledTachoMotor) countsPerDegree() float64 {
+	return float64(m.countPerRot) / 360
+}
+
+// Degrees returns the current position of the ScaledTachoMotor in degrees.
+func (m *ScaledTachoMotor) Degrees() (float64, error) {
+	pos, err := m.Position()
+	return float64(pos) / m.countsPerDegree(), err
+}
+
+// Rotations returns the current position of the ScaledTachoMotor in rotations.
+func (m *ScaledTachoMotor) Rotations() (float64, error) {
+	pos, err := m.Position()
+	return float64(pos) / float64(m.countPerRot), err
+}
+
+// SetPositionDegrees sets the position of the ScaledTachoMotor in degrees.
+func (m *ScaledTachoMotor) SetPositionDegrees(deg float64) *ScaledTachoMotor {
+	m.SetPosition(round.Int(deg * m.countsPerDegree()))
+	return m
+}
+
+// PositionSetpointDegrees returns the current position setpoint of the
+// ScaledTachoMotor in degrees.
+func (m *ScaledTachoMotor) PositionSetpointDegrees() (float64, error) {
+	sp, err := m.PositionSetpoint()
+	return float64(sp) / m.countsPerDegree(), err
+}
+
+// SetPositionSetpointDegrees sets the position setpoint of the ScaledTachoMotor
+// in degrees.
+func (m *ScaledTachoMotor) SetPositionSetpointDegrees(deg float64) *ScaledTachoMotor {
+	m.SetPositionSetpoint(round.Int(deg * m.countsPerDegree()))
+	return m
+}
+
+// SetPositionRotations sets the position of the ScaledTachoMotor in rotations.
+func (m *ScaledTachoMotor) SetPositionRotations(rot float64) *ScaledTachoMotor {
+	m.SetPosition(round.Int(rot * float64(m.countPerRot)))
+	return m
+}
+
+// PositionSetpointRotations returns the current position setpoint of the
+// ScaledTachoMotor in rotations.
+func (m *ScaledTachoMotor) PositionSetpointRotations() (float64, error) {
+	sp, err := m.PositionSetpoint()
+	return float64(sp) / float64(m.countPerRot), err
+}
+
+// SetPositionSetpointRotations sets the position setpoint of the ScaledTachoMotor
+// in rotations.
+func (m *ScaledTachoMotor) SetPositionSetpointRotations(rot float64) *ScaledTachoMotor {
+	m.SetPositionSetpoint(round.Int(rot * float64(m.countPerRot)))
+	return m
+}
+
+// DegreesPerSecond returns the current speed of the ScaledTachoMotor in degrees
+// per second.
+func (m *ScaledTachoMotor) DegreesPerSecond() (float64, error) {
+	sp, err := m.Speed()
+	return float64(sp) / m.countsPerDegree(), err
+}
+
+// RPM returns the current speed of the ScaledTachoMotor in rotations per minute.
+func (m *ScaledTachoMotor) RPM() (float64, error) {
+	sp, err := m.Speed()
+	return float64(sp) * 60 / float64(m.countPerRot), err
+}
+
+// SpeedPercent returns the current speed of the ScaledTachoMotor as a percentage
+// of its maximum speed.
+func (m *ScaledTachoMotor) SpeedPercent() (float64, error) {
+	sp, err := m.Speed()
+	return float64(sp) * 100 / float64(m.maxSpeed), err
+}
+
+// SetSpeedSetpointDegreesPerSecond sets the speed setpoint of the ScaledTachoMotor
+// in degrees per second.
+func (m *ScaledTachoMotor) SetSpeedSetpointDegreesPerSecond(dps float64) *ScaledTachoMotor {
+	m.setSpeedSetpoint(dps * m.countsPerDegree())
+	return m
+}
+
+// SetSpeedSetpointRPM sets the speed setpoint of the ScaledTachoMotor in rotations
+// per minute.
+func (m *ScaledTachoMotor) SetSpeedSetpointRPM(rpm float64) *ScaledTachoMotor {
+	m.setSpeedSetpoint(rpm * float64(m.countPerRot) / 60)
+	return m
+}
+
+// SetSpeedSetpointPercent sets the speed setpoint of the ScaledTachoMotor as a
+// percentage of its maximum speed. The percentage must be in the range [-100, 100].
+func (m *ScaledTachoMotor) SetSpeedSetpointPercent(p float64) *ScaledTachoMotor {
+	if m.err != nil {
+		return m
+	}
+	if p < -100 || 100 < p {
+		m.err = fmt.Errorf("ev3dev: invalid speed percentage for %s: %v (must be in -100-100)", m, p)
+		return m
+	}
+	m.setSpeedSetpoint(p * float64(m.maxSpeed) / 100)
+	return m
+}
+
+// setSpeedSetpoint sets the speed setpoint of the ScaledTachoMotor in counts
+// per second, checking that the speed is within the maximum speed.
+func (m *ScaledTachoMotor) setSpeedSetpoint(cps float64) {
+	if m.err != nil {
+		return
+	}
+	sp := round.Int(cps)
+	if sp < -m.maxSpeed || m.maxSpeed < sp {
+		m.err = newValueOutOfRangeError(m, speedSetpoint, sp, -m.maxSpeed, m.maxSpeed)
+		return
+	}
+	m.SetSpeedSetpoint(sp)
+}
+
+// ScaledLinearActuator wraps a LinearActuator to provide position and speed
+// setpoints and readings in physical units. Positions are in meters, and speeds
+// are in meters per second or as a percentage of the actuator's maximum speed.
+//
+// Conversions use the count_per_m and max_speed attributes read when the
+// ScaledLinearActuator is created. The result and action method semantics
+// of the embedded LinearActuator apply to the ScaledLinearActuator methods.
+type ScaledLinearActuator struct {
+	*LinearActuator
+
+	countPerMeter int
+	maxSpeed      int
+}
+
+// NewScaledLinearActuator returns a ScaledLinearActuator wrapping m.
+func NewScaledLinearActuator(m *LinearActuator) (*ScaledLinearActuator, error) {
+	if m == nil {
+		return nil, errors.New("ev3dev: nil linear actuator")
+	}
+	cpm, err := m.CountPerMeter()
+	if err != nil {
+		return nil, err
+	}
+	if cpm <= 0 {
+		return nil, fmt.Errorf("ev3dev: invalid count per meter for %s: %d", m, cpm)
+	}
+	max, err := m.MaxSpeed()
+	if err != nil {
+		return nil, err
+	}
+	return &ScaledLinearActuator{LinearActuator: m, countPerMeter: cpm, maxSpeed: max}, nil
+}
+
+// Meters returns the current position of the ScaledLinearActuator in meters.
+func (m *ScaledLinearActuator) Meters() (float64, error) {
+	pos, err := m.Position()
+	return float64(pos) / float64(m.countPerMeter), err
+}
+
+// SetPositionMeters sets the position of the ScaledLinearActuator in meters.
+func (m *ScaledLinearActuator) SetPositionMeters(pos float64) *ScaledLinearActuator {
+	m.SetPosition(round.Int(pos * float64(m.countPerMeter)))
+	return m
+}
+
+// PositionSetpointMeters returns the current position setpoint of the
+// ScaledLinearActuator in meters.
+func (m *ScaledLinearActuator) PositionSetpointMeters() (float64, error) {
+	sp, err := m.PositionSetpoint()
+	return float64(sp) / float64(m.countPerMeter), err
+}
+
+// SetPositionSetpointMeters sets the position setpoint of the ScaledLinearActuator
+// in meters.
+func (m *ScaledLinearActuator) SetPositionSetpointMeters(pos float64) *ScaledLinearActuator {
+	m.SetPositionSetpoint(round.Int(pos * float64(m.countPerMeter)))
+	return m
+}
+
+// MetersPerSecond returns the current speed of the ScaledLinearActuator in meters
+// per second.
+func (m *ScaledLinearActuator) MetersPerSecond() (float64, error) {
+	sp, err := m.Speed()
+	return float64(sp) / float64(m.countPerMeter), err
+}
+
+// SpeedPercent returns the current speed of the ScaledLinearActuator as a
+// percentage of its maximum speed.
+func (m *ScaledLinearActuator) SpeedPercent() (float64, error) {
+	sp, err := m.Speed()
+	return float64(sp) * 100 / float64(m.maxSpeed), err
+}
+
+// SetSpeedSetpointMetersPerSecond sets the speed setpoint of the ScaledLinearActuator
+// in meters per second.
+func (m *ScaledLinearActuator) SetSpeedSetpointMetersPerSecond(v float64) *ScaledLinearActuator {
+	m.setSpeedSetpoint(v * float64(m.countPerMeter))
+	return m
+}
+
+// SetSpeedSetpointPercent sets the speed setpoint of the ScaledLinearActuator as a
+// percentage of its maximum speed. The percentage must be in the range [-100, 100].
+func (m *ScaledLinearActuator) SetSpeedSetpointPercent(p float64) *ScaledLinearActuator {
+	if m.err != nil {
+		return m
+	}
+	if p < -100 || 100 < p {
+		m.err = fmt.Errorf("ev3dev: invalid speed percentage for %s: %v (must be in -100-100)", m, p)
+		return m
+	}
+	m.setSpeedSetpoint(p * float64(m.maxSpeed) / 100)
+	return m
+}
+
+// setSpeedSetpoint sets the speed setpoint of the ScaledLinearActuator in counts
+// per second, checking that the speed is within the maximum speed.
+func (m *ScaledLinearActuator) setSpeedSetpoint(cps float64) {
+	if m.err != nil {
+		return
+	}
+	sp := round.Int(cps)
+	if sp < -m.maxSpeed || m.maxSpeed < sp {
+		m.err = newValueOutOfRangeError(m, speedSetpoint, sp, -m.maxSpeed, m.maxSpeed)
+		return
+	}
+	m.SetSpeedSetpoint(sp)
+}
@@ -0,0 +1,208 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// writeAttrs writes the attributes to the device directory dir of mem.
+func writeAttrs(t *testing.T, mem *MemoryBackend, dir string, attrs map[string]string) {
+	for attr, val := range attrs {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+}
+
+// readAttr returns the attribute in the device directory dir of mem.
+func readAttr(t *testing.T, mem *MemoryBackend, dir, attr string) string {
+	b, err := mem.Read(filepath.Join(dir, attr))
+	if err != nil {
+		t.Fatalf("unexpected error reading %s: %v", attr, err)
+	}
+	return string(chomp(b))
+}
+
+func TestScaledTachoMotor(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+
+	// A motor with 420 counts per rotation has
+	// 7 counts for each 6 degrees.
+	dir := filepath.Join(prefix, TachoMotorPath, "motor0")
+	writeAttrs(t, mem, dir, map[string]string{
+		countPerRot: "420",
+		maxSpeed:    "1000",
+	})
+	m, err := NewScaledTachoMotor(&TachoMotor{id: 0})
+	if err != nil {
+		t.Fatalf("unexpected error creating scaled motor: %v", err)
+	}
+
+	setTests := []struct {
+		name string
+		set  func() error
+		attr string
+		want string
+	}{
+		{name: "SetPositionDegrees(90)", set: func() error { return m.SetPositionDegrees(90).Err() }, attr: position, want: "105"},
+		{name: "SetPositionDegrees(-45.5)", set: func() error { return m.SetPositionDegrees(-45.5).Err() }, attr: position, want: "-53"},
+		{name: "SetPositionRotations(1.5)", set: func() error { return m.SetPositionRotations(1.5).Err() }, attr: position, want: "630"},
+		{name: "SetPositionSetpointDegrees(180)", set: func() error { return m.SetPositionSetpointDegrees(180).Err() }, attr: positionSetpoint, want: "210"},
+		{name: "SetPositionSetpointRotations(-0.25)", set: func() error { return m.SetPositionSetpointRotations(-0.25).Err() }, attr: positionSetpoint, want: "-105"},
+		{name: "SetSpeedSetpointDegreesPerSecond(360)", set: func() error { return m.SetSpeedSetpointDegreesPerSecond(360).Err() }, attr: speedSetpoint, want: "420"},
+		{name: "SetSpeedSetpointRPM(60)", set: func() error { return m.SetSpeedSetpointRPM(60).Err() }, attr: speedSetpoint, want: "420"},
+		{name: "SetSpeedSetpointRPM(-30)", set: func() error { return m.SetSpeedSetpointRPM(-30).Err() }, attr: speedSetpoint, want: "-210"},
+		{name: "SetSpeedSetpointPercent(50)", set: func() error { return m.SetSpeedSetpointPercent(50).Err() }, attr: speedSetpoint, want: "500"},
+		{name: "SetSpeedSetpointPercent(-12.34)", set: func() error { return m.SetSpeedSetpointPercent(-12.34).Err() }, attr: speedSetpoint, want: "-123"},
+	}
+	for _, test := range setTests {
+		err := test.set()
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+			continue
+		}
+		if got := readAttr(t, mem, dir, test.attr); got != test.want {
+			t.Errorf("unexpected %s for %s: got:%s want:%s", test.attr, test.name, got, test.want)
+		}
+	}
+
+	writeAttrs(t, mem, dir, map[string]string{
+		position:         "105",
+		positionSetpoint: "-630",
+		speed:            "210",
+	})
+	getTests := []struct {
+		name string
+		get  func() (float64, error)
+		want float64
+	}{
+		{name: "Degrees", get: m.Degrees, want: 90},
+		{name: "Rotations", get: m.Rotations, want: 0.25},
+		{name: "PositionSetpointDegrees", get: m.PositionSetpointDegrees, want: -540},
+		{name: "PositionSetpointRotations", get: m.PositionSetpointRotations, want: -1.5},
+		{name: "DegreesPerSecond", get: m.DegreesPerSecond, want: 180},
+		{name: "RPM", get: m.RPM, want: 30},
+		{name: "SpeedPercent", get: m.SpeedPercent, want: 21},
+	}
+	for _, test := range getTests {
+		got, err := test.get()
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("unexpected value for %s: got:%v want:%v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestScaledLinearActuator(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+
+	dir := filepath.Join(prefix, TachoMotorPath, "linear0")
+	writeAttrs(t, mem, dir, map[string]string{
+		countPerMeter: "2500",
+		maxSpeed:      "250",
+	})
+	m, err := NewScaledLinearActuator(&LinearActuator{id: 0})
+	if err != nil {
+		t.Fatalf("unexpected error creating scaled actuator: %v", err)
+	}
+
+	setTests := []struct {
+		name string
+		set  func() error
+		attr string
+		want string
+	}{
+		{name: "SetPositionMeters(0.05)", set: func() error { return m.SetPositionMeters(0.05).Err() }, attr: position, want: "125"},
+		{name: "SetPositionMeters(-0.0124)", set: func() error { return m.SetPositionMeters(-0.0124).Err() }, attr: position, want: "-31"},
+		{name: "SetPositionSetpointMeters(0.1)", set: func() error { return m.SetPositionSetpointMeters(0.1).Err() }, attr: positionSetpoint, want: "250"},
+		{name: "SetSpeedSetpointMetersPerSecond(0.04)", set: func() error { return m.SetSpeedSetpointMetersPerSecond(0.04).Err() }, attr: speedSetpoint, want: "100"},
+		{name: "SetSpeedSetpointPercent(-40)", set: func() error { return m.SetSpeedSetpointPercent(-40).Err() }, attr: speedSetpoint, want: "-100"},
+	}
+	for _, test := range setTests {
+		err := test.set()
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+			continue
+		}
+		if got := readAttr(t, mem, dir, test.attr); got != test.want {
+			t.Errorf("unexpected %s for %s: got:%s want:%s", test.attr, test.name, got, test.want)
+		}
+	}
+
+	writeAttrs(t, mem, dir, map[string]string{
+		position:         "500",
+		positionSetpoint: "1250",
+		speed:            "-125",
+	})
+	getTests := []struct {
+		name string
+		get  func() (float64, error)
+		want float64
+	}{
+		{name: "Meters", get: m.Meters, want: 0.2},
+		{name: "PositionSetpointMeters", get: m.PositionSetpointMeters, want: 0.5},
+		{name: "MetersPerSecond", get: m.MetersPerSecond, want: -0.05},
+		{name: "SpeedPercent", get: m.SpeedPercent, want: -50},
+	}
+	for _, test := range getTests {
+		got, err := test.get()
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.name, err)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("unexpected value for %s: got:%v want:%v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestScaledTachoMotorSpeedRange(t *testing.T) {
+	m := &ScaledTachoMotor{TachoMotor: &TachoMotor{}, countPerRot: 360, maxSpeed: 1050}
+
+	for _, p := range []float64{-100.5, 100.5, 200} {
+		err := m.SetSpeedSetpointPercent(p).Err()
+		if err == nil {
+			t.Errorf("expected error for speed percentage %v", p)
+		}
+	}
+
+	// 180 rpm is 1080 counts per second which exceeds
+	// the maximum speed of 1050 counts per second.
+	err := m.SetSpeedSetpointRPM(180).Err()
+	if _, ok := err.(ValidRanger); !ok {
+		t.Errorf("unexpected error type for out of range speed: got:%T want:ValidRanger", err)
+	}
+	err = m.SetSpeedSetpointDegreesPerSecond(-1080).Err()
+	if _, ok := err.(ValidRanger); !ok {
+		t.Errorf("unexpected error type for out of range speed: got:%T want:ValidRanger", err)
+	}
+}
+
+func TestScaledLinearActuatorSpeedRange(t *testing.T) {
+	m := &ScaledLinearActuator{LinearActuator: &LinearActuator{}, countPerMeter: 1000, maxSpeed: 100}
+
+	for _, p := range []float64{-101, 101} {
+		err := m.SetSpeedSetpointPercent(p).Err()
+		if err == nil {
+			t.Errorf("expected error for speed percentage %v", p)
+		}
+	}
+
+	err := m.SetSpeedSetpointMetersPerSecond(0.2).Err()
+	if _, ok := err.(ValidRanger); !ok {
+		t.Errorf("unexpected error type for out of range speed: got:%T want:ValidRanger", err)
+	}
+}
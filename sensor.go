@@ -6,7 +6,7 @@ package ev3dev
 
 import (
 	"fmt"
-	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"time"
@@ -18,9 +18,22 @@ var _ idSetter = (*Sensor)(nil)
 type Sensor struct {
 	id int
 
+	// meta is the cached metadata for
+	// the current mode of the Sensor.
+	// It is invalidated by SetMode.
+	meta *sensorMeta
+
 	err error
 }
 
+// sensorMeta holds the value metadata for a sensor mode.
+type sensorMeta struct {
+	numValues     int
+	scale         float64
+	units         string
+	binDataFormat string
+}
+
 // Path returns the lego-sensor sysfs path.
 func (*Sensor) Path() string { return filepath.Join(prefix, SensorPath) }
 
@@ -84,7 +97,7 @@ func (s *Sensor) BinData() ([]byte, error) {
 		return nil, err
 	}
 	path := filepath.Join(s.Path(), s.String(), binData)
-	b, err := ioutil.ReadFile(path)
+	b, err := backend.Read(path)
 	if err != nil {
 		return nil, fmt.Errorf("ev3dev: failed to read attribute %s: %v", path, err)
 	}
@@ -171,6 +184,7 @@ func (s *Sensor) SetMode(m string) *Sensor {
 	if s.err != nil {
 		return s
 	}
+	s.meta = nil
 	s.err = setAttributeOf(s, mode, m)
 	return s
 }
@@ -205,6 +219,58 @@ func (s *Sensor) Value(n int) (string, error) {
 	return stringFrom(attributeOf(s, fmt.Sprint(value, n)))
 }
 
+// ScaledValues returns all the values measured by the Sensor, scaled according
+// to the number of decimal places for the current mode, and the units of the
+// values.
+//
+// The number of values, decimal places and units are read on the first call
+// to ScaledValues after the Sensor handle is obtained or its mode is set with
+// SetMode, and are cached until the next call to SetMode. If the mode of the
+// sensor is changed by another handle, the cached metadata will be stale.
+func (s *Sensor) ScaledValues() (values []float64, units string, err error) {
+	err = s.Err()
+	if err != nil {
+		return nil, "", err
+	}
+	if s.meta == nil {
+		meta, err := s.modeMeta()
+		if err != nil {
+			return nil, "", err
+		}
+		s.meta = meta
+	}
+	values = make([]float64, s.meta.numValues)
+	for i := range values {
+		v, err := float64From(attributeOf(s, fmt.Sprint(value, i)))
+		if err != nil {
+			return nil, "", err
+		}
+		values[i] = v / s.meta.scale
+	}
+	return values, s.meta.units, nil
+}
+
+// modeMeta returns the value metadata for the current mode of the Sensor.
+func (s *Sensor) modeMeta() (*sensorMeta, error) {
+	n, err := s.NumValues()
+	if err != nil {
+		return nil, err
+	}
+	dp, err := s.Decimals()
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.Units()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.BinDataFormat()
+	if err != nil {
+		return nil, err
+	}
+	return &sensorMeta{numValues: n, scale: math.Pow10(dp), units: u, binDataFormat: f}, nil
+}
+
 // TextValues returns slice of strings string representing sensor-specific text values.
 func (s *Sensor) TextValues() ([]string, error) {
 	return stringSliceFrom(attributeOf(s, textValues))
@@ -0,0 +1,109 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SensorSample is a timestamped sample of the values measured by a Sensor.
+type SensorSample struct {
+	// Time is the time the sample
+	// was read from the Sensor.
+	Time time.Time
+
+	// Values and Units hold the scaled
+	// values and units of the sample as
+	// returned by Sensor.ScaledValues.
+	Values []float64
+	Units  string
+
+	// Dropped is the number of samples
+	// that were discarded since the last
+	// delivered sample because the receiver
+	// was not ready to receive them.
+	Dropped int
+
+	// Err is any error that occurred
+	// while reading the sample.
+	Err error
+}
+
+// Stream starts sampling the Sensor at the given period, sending timestamped
+// samples on the returned channel until ctx is cancelled, when the channel is
+// closed. The buffer parameter specifies the capacity of the returned channel.
+// If the receiver is not ready to receive a sample when it is read, the sample
+// is dropped and counted in the Dropped field of the next delivered sample.
+//
+// If the Sensor has a poll rate that is slower than the requested period, Stream
+// attempts to set the poll rate to the period, or to one millisecond for shorter
+// periods. If this fails, samples are read at the Sensor's poll rate, since
+// sampling faster than this would only repeat values.
+//
+// The Sensor must not be used by other goroutines until the returned channel
+// is closed.
+func (s *Sensor) Stream(ctx context.Context, period time.Duration, buffer int) (<-chan SensorSample, error) {
+	err := s.Err()
+	if err != nil {
+		return nil, err
+	}
+	if period <= 0 {
+		return nil, fmt.Errorf("ev3dev: invalid sample period for %s: %v (must be positive)", s, period)
+	}
+	if buffer < 0 {
+		return nil, fmt.Errorf("ev3dev: invalid sample buffer length for %s: %d", s, buffer)
+	}
+
+	// Not all sensors have a poll rate, so an
+	// error here indicates we can use any period.
+	// The poll rate is written in milliseconds and
+	// zero disables polling, so it is limited to
+	// at least one millisecond.
+	rate := period
+	if rate < time.Millisecond {
+		rate = time.Millisecond
+	}
+	poll, err := s.PollRate()
+	if err == nil && poll > rate {
+		err = s.SetPollRate(rate).Err()
+		if err != nil {
+			period = poll
+		}
+	}
+
+	c := make(chan SensorSample, buffer)
+	go func() {
+		defer close(c)
+
+		tick := time.NewTicker(period)
+		defer tick.Stop()
+
+		var dropped int
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+
+			var sample SensorSample
+			sample.Values, sample.Units, sample.Err = s.ScaledValues()
+			sample.Time = time.Now()
+			sample.Dropped = dropped
+
+			select {
+			case <-ctx.Done():
+				return
+			case c <- sample:
+				dropped = 0
+			default:
+				dropped++
+			}
+		}
+	}()
+	return c, nil
+}
@@ -5,9 +5,11 @@
 package ev3dev_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -553,6 +555,7 @@ func connectedSensors(c ...sensorConn) []sisyphus.Node {
 			ro(NumValuesName, 0444, (*sensorNumValues)(s.sensor)),
 			ro(ValueName+"0", 0444, sensorValue{0, s.sensor}),
 			ro(ValueName+"1", 0444, sensorValue{1, s.sensor}),
+			ro(ValueName+"2", 0444, sensorValue{2, s.sensor}),
 			ro(TextValuesName, 0444, (*sensorTextValues)(s.sensor)),
 			ro(UeventName, 0444, (*sensorUevent)(s.sensor)),
 		)
@@ -923,6 +926,58 @@ func TestSensor(t *testing.T) {
 		}
 	})
 
+	t.Run("Stream", func(t *testing.T) {
+		s, err := SensorFor(conn[0].sensor.address, conn[0].sensor.driver)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = s.SetPollRate(time.Second).Err()
+		if err != nil {
+			t.Fatalf("unexpected error setting poll rate: %v", err)
+		}
+
+		const period = 10 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		samples, err := s.Stream(ctx, period, 0)
+		if err != nil {
+			t.Fatalf("unexpected error starting stream: %v", err)
+		}
+
+		var last time.Time
+		for i := 0; i < 5; i++ {
+			sample := <-samples
+			if sample.Err != nil {
+				t.Errorf("unexpected error in sample: %v", sample.Err)
+			}
+			if !sample.Time.After(last) {
+				t.Errorf("sample time not increasing: %v <= %v", sample.Time, last)
+			}
+			last = sample.Time
+			if len(sample.Values) != len(conn[0].sensor.values()) {
+				t.Errorf("unexpected number of values: got:%d want:%d", len(sample.Values), len(conn[0].sensor.values()))
+			}
+		}
+
+		// Let the stream run without a receiver.
+		time.Sleep(10 * period)
+		sample := <-samples
+		if sample.Dropped == 0 {
+			t.Error("expected dropped samples after stalling receiver")
+		}
+
+		cancel()
+		for range samples {
+		}
+
+		got, err := s.PollRate()
+		if err != nil {
+			t.Errorf("unexpected error getting poll rate: %v", err)
+		}
+		if got != period {
+			t.Errorf("unexpected poll rate value: got:%v want:%v", got, period)
+		}
+	})
+
 	t.Run("Units", func(t *testing.T) {
 		s, err := SensorFor(conn[0].sensor.address, conn[0].sensor.driver)
 		if err != nil {
@@ -1025,6 +1080,47 @@ func TestSensor(t *testing.T) {
 		}
 	})
 
+	t.Run("Scaled values", func(t *testing.T) {
+		s, err := SensorFor(conn[0].sensor.address, conn[0].sensor.driver)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, mode := range []string{"GYRO-ANG", "GYRO-RATE"} {
+			err := s.SetMode(mode).Err()
+			if err != nil {
+				t.Errorf("unexpected error for mode %q: %v", mode, err)
+			}
+
+			for _, attr := range []string{UnitsName, DecimalsName} {
+				err = fs.InvalidatePath(filepath.Join(s.Path(), s.String(), attr))
+				if err != nil {
+					t.Fatalf("unexpected error invalidating %s: %v", attr, err)
+				}
+			}
+
+			got, gotUnits, err := s.ScaledValues()
+			if err != nil {
+				t.Errorf("unexpected error getting scaled values: %v", err)
+			}
+			scale := math.Pow10(conn[0].sensor.decimals())
+			var want []float64
+			for _, v := range conn[0].sensor.values() {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					t.Fatalf("unexpected error parsing value: %v", err)
+				}
+				want = append(want, f/scale)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected scaled values: got:%v want:%v", got, want)
+			}
+			wantUnits := conn[0].sensor.units()
+			if gotUnits != wantUnits {
+				t.Errorf("unexpected units: got:%q want:%q", gotUnits, wantUnits)
+			}
+		}
+	})
+
 	t.Run("Text values", func(t *testing.T) {
 		for _, c := range conn {
 			s, err := SensorFor(c.sensor.address, c.sensor.driver)
@@ -1059,3 +1155,49 @@ func TestSensor(t *testing.T) {
 		}
 	})
 }
+
+func TestSensorStreamShortPeriod(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+
+	dir := filepath.Join(Prefix, SensorPath, "sensor0")
+	for attr, val := range map[string]string{
+		AddressName:       "in1",
+		DriverNameName:    "lego-ev3-touch",
+		PollRateName:      "50",
+		ModeName:          "TOUCH",
+		NumValuesName:     "1",
+		DecimalsName:      "0",
+		UnitsName:         "",
+		BinDataFormatName: "s8",
+		ValueName + "0":   "1",
+	} {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+	s, err := SensorFor("in1", "lego-ev3-touch")
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, err := s.Stream(ctx, 500*time.Microsecond, 0)
+	if err != nil {
+		t.Fatalf("unexpected error starting stream: %v", err)
+	}
+	sample := <-samples
+	cancel()
+	for range samples {
+	}
+	if sample.Err != nil {
+		t.Errorf("unexpected error in sample: %v", sample.Err)
+	}
+
+	got, err := s.PollRate()
+	if err != nil || got != time.Millisecond {
+		t.Errorf("unexpected poll rate for sub-millisecond period: got:%v want:%v (err=%v)", got, time.Millisecond, err)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sim provides simulated ev3dev devices for hardware-free development.
+//
+// A Sim materialises a sysfs-like tree of regular files in a directory and
+// updates it as simulated time advances. Programs using the ev3dev package
+// can be run against the simulated devices by setting the ev3dev prefix to
+// the simulation root:
+//
+//	s, err := sim.New(dir)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	_, err = s.AddTachoMotor("outA", "lego-ev3-l-motor")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = s.Start(10 * time.Millisecond)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer s.Close()
+//	ev3dev.SetPrefix(s.Root())
+//
+//	m, err := ev3dev.TachoMotorFor("outA", "lego-ev3-l-motor")
+//	...
+package sim
@@ -0,0 +1,106 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// ledTriggers are the triggers available to simulated LEDs.
+var ledTriggers = []string{"none", "timer", "heartbeat", "default-on"}
+
+// LED is a simulated LED.
+type LED struct {
+	sim *Sim
+	attrs
+
+	name       string
+	brightness int
+	trigger    string
+}
+
+// AddLED adds a simulated LED with the given name, for example
+// "led0:green:brick-status".
+func (s *Sim) AddLED(name string) (*LED, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := newAttrs(filepath.Join(s.root, ev3dev.LEDPath, name))
+	if err != nil {
+		return nil, err
+	}
+	l := &LED{sim: s, attrs: a, name: name, trigger: "none"}
+	for _, attr := range []struct{ name, val string }{
+		{"max_brightness", "255"},
+		{"brightness", "0"},
+		{"delay_on", "500"},
+		{"delay_off", "500"},
+		{"trigger", l.triggers()},
+		{"uevent", ""},
+	} {
+		err = l.set(attr.name, attr.val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.leds = append(s.leds, l)
+	return l, nil
+}
+
+// Name returns the sysfs device name of the LED.
+func (l *LED) Name() string { return l.name }
+
+// Brightness returns the current brightness of the LED.
+func (l *LED) Brightness() int {
+	l.sim.mu.Lock()
+	defer l.sim.mu.Unlock()
+	return l.brightness
+}
+
+// Trigger returns the current trigger of the LED.
+func (l *LED) Trigger() string {
+	l.sim.mu.Lock()
+	defer l.sim.mu.Unlock()
+	return l.trigger
+}
+
+// triggers returns the trigger attribute value with the current
+// trigger marked.
+func (l *LED) triggers() string {
+	t := make([]string, len(ledTriggers))
+	for i, n := range ledTriggers {
+		if n == l.trigger {
+			n = "[" + n + "]"
+		}
+		t[i] = n
+	}
+	return strings.Join(t, " ")
+}
+
+// step applies brightness and trigger changes made by the user.
+// l.sim.mu must be held.
+func (l *LED) step() error {
+	val, ok, err := l.changed("brightness")
+	if err != nil {
+		return err
+	}
+	if ok {
+		if v, err := strconv.Atoi(val); err == nil && 0 <= v && v <= 255 {
+			l.brightness = v
+		}
+	}
+	val, ok, err = l.changed("trigger")
+	if err != nil || !ok {
+		return err
+	}
+	if contains(ledTriggers, val) {
+		l.trigger = val
+	}
+	return l.set("trigger", l.triggers())
+}
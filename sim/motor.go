@@ -0,0 +1,480 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+// Motor commands and stop actions supported by simulated tacho motors.
+var (
+	motorCommands    = []string{"run-forever", "run-to-abs-pos", "run-to-rel-pos", "run-timed", "run-direct", "stop", "reset"}
+	motorStopActions = []string{"coast", "brake", "hold"}
+)
+
+// motor run modes.
+const (
+	stopped = iota
+	runForever
+	runToPos
+	runTimed
+	runDirect
+)
+
+// TachoMotor is a simulated tacho motor.
+//
+// The simulated motor accelerates towards the speed required by the current
+// command, limited by the ramp setpoints or by its acceleration when the ramp
+// setpoints are zero. As with the ev3dev tacho motor driver, the speed and ramp
+// setpoints take effect when a run command is issued, while the duty cycle
+// setpoint takes effect immediately in run-direct mode. When stopped it decelerates according to the stop action,
+// and with the hold stop action it reports the holding state once stationary.
+// If limits are set, the motor stalls when driven against them.
+type TachoMotor struct {
+	sim *Sim
+	attrs
+
+	name    string
+	address string
+	driver  string
+
+	countPerRot int
+	maxSpeed    int
+
+	// accel is the acceleration used when
+	// the ramp setpoints are zero, in counts
+	// per second per second.
+	accel float64
+
+	// limited indicates whether the motor
+	// travel is limited to [min, max].
+	limited  bool
+	min, max float64
+
+	// Attribute values set by the user.
+	speedSP    int
+	positionSP int
+	dutySP     int
+	timeSP     time.Duration
+	rampUp     time.Duration
+	rampDown   time.Duration
+	stopAction string
+
+	// Setpoints latched when the
+	// last run command was issued.
+	runSpeed    int
+	runRampUp   time.Duration
+	runRampDown time.Duration
+
+	// Dynamic state.
+	pos     float64
+	vel     float64
+	mode    int
+	target  float64
+	remain  time.Duration
+	ramping bool
+	stalled bool
+}
+
+// AddTachoMotor adds a simulated tacho motor with the given port address and
+// driver name. The lego-ev3-l-motor and lego-ev3-m-motor drivers are given
+// the maximum speeds of the corresponding LEGO motors.
+func (s *Sim) AddTachoMotor(address, driver string) (*TachoMotor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := fmt.Sprintf("motor%d", s.nMotor)
+	a, err := newAttrs(filepath.Join(s.root, ev3dev.TachoMotorPath, name))
+	if err != nil {
+		return nil, err
+	}
+	m := &TachoMotor{
+		sim:   s,
+		attrs: a,
+
+		name:    name,
+		address: address,
+		driver:  driver,
+
+		countPerRot: 360,
+		maxSpeed:    1000,
+	}
+	switch driver {
+	case "lego-ev3-l-motor":
+		m.maxSpeed = 1050
+	case "lego-ev3-m-motor":
+		m.maxSpeed = 1560
+	}
+	m.accel = 10 * float64(m.maxSpeed)
+
+	for _, attr := range []struct{ name, val string }{
+		{"address", address},
+		{"driver_name", driver},
+		{"commands", strings.Join(motorCommands, " ")},
+		{"stop_actions", strings.Join(motorStopActions, " ")},
+		{"count_per_rot", fmt.Sprint(m.countPerRot)},
+		{"max_speed", fmt.Sprint(m.maxSpeed)},
+		{"uevent", uevent(address, driver)},
+		{"command", ""},
+	} {
+		err = m.set(attr.name, attr.val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = m.reset()
+	if err != nil {
+		return nil, err
+	}
+
+	s.nMotor++
+	s.motors = append(s.motors, m)
+	return m, nil
+}
+
+// Name returns the sysfs device name of the motor.
+func (m *TachoMotor) Name() string { return m.name }
+
+// SetLimits limits the travel of the motor to between min and max
+// tacho counts. The motor stalls when driven against a limit.
+func (m *TachoMotor) SetLimits(min, max int) error {
+	if min > max {
+		return fmt.Errorf("sim: invalid motor limits: min=%d max=%d", min, max)
+	}
+	m.sim.mu.Lock()
+	defer m.sim.mu.Unlock()
+	m.limited = true
+	m.min, m.max = float64(min), float64(max)
+	return nil
+}
+
+// ClearLimits removes any travel limits on the motor.
+func (m *TachoMotor) ClearLimits() {
+	m.sim.mu.Lock()
+	m.limited = false
+	m.sim.mu.Unlock()
+}
+
+// Position returns the simulated position of the motor in tacho counts.
+func (m *TachoMotor) Position() float64 {
+	m.sim.mu.Lock()
+	defer m.sim.mu.Unlock()
+	return m.pos
+}
+
+// Speed returns the simulated speed of the motor in tacho counts per second.
+func (m *TachoMotor) Speed() float64 {
+	m.sim.mu.Lock()
+	defer m.sim.mu.Unlock()
+	return m.vel
+}
+
+// State returns the simulated state of the motor.
+func (m *TachoMotor) State() ev3dev.MotorState {
+	m.sim.mu.Lock()
+	defer m.sim.mu.Unlock()
+	return m.state()
+}
+
+// reset returns the motor to its initial state.
+func (m *TachoMotor) reset() error {
+	m.speedSP, m.positionSP, m.dutySP = 0, 0, 0
+	m.timeSP, m.rampUp, m.rampDown = 0, 0, 0
+	m.runSpeed, m.runRampUp, m.runRampDown = 0, 0, 0
+	m.stopAction = "coast"
+	m.pos, m.vel = 0, 0
+	m.mode = stopped
+	m.ramping, m.stalled = false, false
+	for _, attr := range []struct{ name, val string }{
+		{"position", "0"},
+		{"speed_sp", "0"},
+		{"position_sp", "0"},
+		{"duty_cycle_sp", "0"},
+		{"time_sp", "0"},
+		{"ramp_up_sp", "0"},
+		{"ramp_down_sp", "0"},
+		{"stop_action", m.stopAction},
+		{"polarity", string(ev3dev.Normal)},
+		{"speed_pid/Kp", "1000"},
+		{"speed_pid/Ki", "60"},
+		{"speed_pid/Kd", "0"},
+		{"hold_pid/Kp", "20000"},
+		{"hold_pid/Ki", "0"},
+		{"hold_pid/Kd", "0"},
+	} {
+		err := m.set(attr.name, attr.val)
+		if err != nil {
+			return err
+		}
+	}
+	return m.update()
+}
+
+// step reads user changes to the motor attributes, advances the motor
+// dynamics by dt and publishes the new motor state. m.sim.mu must be held.
+func (m *TachoMotor) step(dt time.Duration) error {
+	err := m.read()
+	if err != nil {
+		return err
+	}
+	h := dt.Seconds()
+
+	want, active := m.wantSpeed()
+	rate := m.rate(want)
+	dv := want - m.vel
+	if max := rate * h; math.Abs(dv) > max {
+		dv = math.Copysign(max, dv)
+	}
+	m.vel += dv
+	m.ramping = active && m.vel != want && (m.runRampUp != 0 || m.runRampDown != 0)
+	before := m.target - m.pos
+	m.pos += m.vel * h
+
+	if m.mode == runToPos {
+		rem := m.target - m.pos
+		if rem*before <= 0 || math.Abs(rem) < 0.5 {
+			m.pos = m.target
+			m.vel = 0
+			m.stop()
+		}
+	}
+	if m.mode == runTimed {
+		m.remain -= dt
+		if m.remain <= 0 {
+			m.stop()
+		}
+	}
+
+	m.stalled = false
+	if m.limited {
+		switch {
+		case m.pos <= m.min:
+			m.pos = m.min
+			if m.vel < 0 {
+				m.vel = 0
+			}
+			m.stalled = m.mode != stopped && want < 0
+		case m.pos >= m.max:
+			m.pos = m.max
+			if m.vel > 0 {
+				m.vel = 0
+			}
+			m.stalled = m.mode != stopped && want > 0
+		}
+		if m.stalled {
+			m.ramping = false
+		}
+	}
+	return m.update()
+}
+
+// read reads the attributes that may be written by the user and
+// executes any issued command.
+func (m *TachoMotor) read() error {
+	for _, attr := range []struct {
+		name string
+		dst  *int
+	}{
+		{"speed_sp", &m.speedSP},
+		{"position_sp", &m.positionSP},
+		{"duty_cycle_sp", &m.dutySP},
+	} {
+		val, ok, err := m.changed(attr.name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(val); err == nil {
+			*attr.dst = v
+		}
+	}
+	for _, attr := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"time_sp", &m.timeSP},
+		{"ramp_up_sp", &m.rampUp},
+		{"ramp_down_sp", &m.rampDown},
+	} {
+		val, ok, err := m.changed(attr.name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(val); err == nil && v >= 0 {
+			*attr.dst = time.Duration(v) * time.Millisecond
+		}
+	}
+	val, ok, err := m.changed("stop_action")
+	if err != nil {
+		return err
+	}
+	if ok && contains(motorStopActions, val) {
+		m.stopAction = val
+	}
+	val, ok, err = m.changed("position")
+	if err != nil {
+		return err
+	}
+	if ok {
+		if v, err := strconv.Atoi(val); err == nil {
+			m.pos = float64(v)
+		}
+	}
+
+	comm, ok, err := m.take("command")
+	if err != nil || !ok {
+		return err
+	}
+	if strings.HasPrefix(comm, "run-") && contains(motorCommands, comm) {
+		m.runSpeed, m.runRampUp, m.runRampDown = m.speedSP, m.rampUp, m.rampDown
+	}
+	switch comm {
+	case "run-forever":
+		m.mode = runForever
+	case "run-to-abs-pos":
+		m.mode = runToPos
+		m.target = float64(m.positionSP)
+	case "run-to-rel-pos":
+		m.mode = runToPos
+		m.target = m.pos + float64(m.positionSP)
+	case "run-timed":
+		m.mode = runTimed
+		m.remain = m.timeSP
+	case "run-direct":
+		m.mode = runDirect
+	case "stop":
+		m.stop()
+	case "reset":
+		return m.reset()
+	}
+	return nil
+}
+
+// stop stops the motor with the current stop action.
+func (m *TachoMotor) stop() {
+	m.mode = stopped
+}
+
+// wantSpeed returns the speed the motor is being driven towards and
+// whether the motor is actively driven.
+func (m *TachoMotor) wantSpeed() (want float64, active bool) {
+	sp := clamp(float64(m.runSpeed), float64(m.maxSpeed))
+	switch m.mode {
+	case runForever, runTimed:
+		return sp, true
+	case runDirect:
+		return clamp(float64(m.dutySP), 100) * float64(m.maxSpeed) / 100, true
+	case runToPos:
+		rem := m.target - m.pos
+		decel := m.accel
+		if m.runRampDown != 0 {
+			decel = float64(m.maxSpeed) / m.runRampDown.Seconds()
+		}
+		v := math.Min(math.Abs(sp), math.Sqrt(2*decel*math.Abs(rem)))
+		return math.Copysign(v, rem), true
+	default:
+		return 0, false
+	}
+}
+
+// rate returns the acceleration limit for changing speed to want.
+func (m *TachoMotor) rate(want float64) float64 {
+	if m.mode == stopped {
+		switch m.stopAction {
+		case "coast":
+			return m.accel / 4
+		default:
+			return m.accel
+		}
+	}
+	ramp := m.runRampDown
+	if math.Abs(want) > math.Abs(m.vel) && want*m.vel >= 0 {
+		ramp = m.runRampUp
+	}
+	if ramp == 0 || m.mode == runDirect {
+		return m.accel
+	}
+	return float64(m.maxSpeed) / ramp.Seconds()
+}
+
+// state returns the current motor state.
+func (m *TachoMotor) state() ev3dev.MotorState {
+	var stat ev3dev.MotorState
+	switch {
+	case m.mode != stopped:
+		stat |= ev3dev.Running
+		if m.ramping {
+			stat |= ev3dev.Ramping
+		}
+		if m.stalled {
+			stat |= ev3dev.Stalled
+		}
+	case m.stopAction == "hold" && m.vel == 0:
+		stat |= ev3dev.Holding
+	}
+	return stat
+}
+
+// update writes the dynamic motor attributes.
+func (m *TachoMotor) update() error {
+	err := m.publish("position", fmt.Sprint(round.Int(m.pos)))
+	if err != nil {
+		return err
+	}
+	for _, attr := range []struct{ name, val string }{
+		{"speed", fmt.Sprint(round.Int(m.vel))},
+		{"duty_cycle", fmt.Sprint(round.Int(100 * m.vel / float64(m.maxSpeed)))},
+	} {
+		err := m.set(attr.name, attr.val)
+		if err != nil {
+			return err
+		}
+	}
+	stat := m.state()
+	if stat == 0 {
+		return m.set("state", "")
+	}
+	return m.set("state", strings.Replace(stat.String(), "|", " ", -1))
+}
+
+// Remove removes the motor from the simulation, as if it had been
+// unplugged.
+func (m *TachoMotor) Remove() error {
+	m.sim.mu.Lock()
+	defer m.sim.mu.Unlock()
+	for i, o := range m.sim.motors {
+		if o == m {
+			m.sim.motors = append(m.sim.motors[:i], m.sim.motors[i+1:]...)
+			break
+		}
+	}
+	return m.remove()
+}
+
+func clamp(v, max float64) float64 {
+	return math.Max(-max, math.Min(v, max))
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
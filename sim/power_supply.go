@@ -0,0 +1,76 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ev3go/ev3dev"
+	"github.com/ev3go/ev3dev/internal/round"
+)
+
+// PowerSupply is a simulated power supply.
+type PowerSupply struct {
+	sim *Sim
+	attrs
+
+	name string
+}
+
+// AddPowerSupply adds a simulated battery power supply with the given name,
+// for example "lego-ev3-battery", and initial voltage in volts.
+func (s *Sim) AddPowerSupply(name string, voltage float64) (*PowerSupply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := newAttrs(filepath.Join(s.root, ev3dev.PowerSupplyPath, name))
+	if err != nil {
+		return nil, err
+	}
+	p := &PowerSupply{sim: s, attrs: a, name: name}
+	for _, attr := range []struct{ name, val string }{
+		{"voltage_max_design", "9000000"},
+		{"voltage_min_design", "6000000"},
+		{"current_now", "0"},
+		{"technology", "Unknown"},
+		{"type", "Battery"},
+		{"uevent", "POWER_SUPPLY_NAME=" + name},
+	} {
+		err = p.set(attr.name, attr.val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = p.setVoltage(voltage)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Name returns the sysfs device name of the power supply.
+func (p *PowerSupply) Name() string { return p.name }
+
+// SetVoltage sets the voltage of the power supply in volts.
+func (p *PowerSupply) SetVoltage(v float64) error {
+	p.sim.mu.Lock()
+	defer p.sim.mu.Unlock()
+	return p.setVoltage(v)
+}
+
+func (p *PowerSupply) setVoltage(v float64) error {
+	if v < 0 {
+		return fmt.Errorf("sim: invalid voltage: %v", v)
+	}
+	return p.set("voltage_now", fmt.Sprint(round.Int(v*1e6)))
+}
+
+// SetCurrent sets the current drawn from the power supply in amps.
+func (p *PowerSupply) SetCurrent(i float64) error {
+	p.sim.mu.Lock()
+	defer p.sim.mu.Unlock()
+	return p.set("current_now", fmt.Sprint(round.Int(i*1e6)))
+}
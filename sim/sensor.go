@@ -0,0 +1,195 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// maxValues is the number of value attributes of a sensor.
+const maxValues = 8
+
+// SensorMode describes a mode of a simulated sensor.
+type SensorMode struct {
+	// Name is the name of the mode.
+	Name string
+
+	// NumValues is the number of values
+	// provided in the mode, Decimals is
+	// the number of decimal places in the
+	// values and Units is their units.
+	NumValues int
+	Decimals  int
+	Units     string
+
+	// BinDataFormat is the format of
+	// the raw values, for example "s8".
+	BinDataFormat string
+}
+
+// Sensor is a simulated sensor. Sensor values are set by calling SetValues.
+type Sensor struct {
+	sim *Sim
+	attrs
+
+	name  string
+	modes []SensorMode
+	mode  int
+}
+
+// AddSensor adds a simulated sensor with the given port address, driver name
+// and modes. The first mode is the initial mode of the sensor.
+func (s *Sim) AddSensor(address, driver string, modes ...SensorMode) (*Sensor, error) {
+	if len(modes) == 0 {
+		return nil, errors.New("sim: no sensor modes")
+	}
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		if m.Name == "" || strings.Contains(m.Name, " ") {
+			return nil, fmt.Errorf("sim: invalid sensor mode name: %q", m.Name)
+		}
+		if m.NumValues < 0 || m.NumValues > maxValues {
+			return nil, fmt.Errorf("sim: invalid number of values for mode %s: %d", m.Name, m.NumValues)
+		}
+		if m.NumValues != 0 && ev3dev.BinDataSize(m.BinDataFormat) < 0 {
+			return nil, fmt.Errorf("sim: invalid bin data format for mode %s: %q", m.Name, m.BinDataFormat)
+		}
+		names[i] = m.Name
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := fmt.Sprintf("sensor%d", s.nSensor)
+	a, err := newAttrs(filepath.Join(s.root, ev3dev.SensorPath, name))
+	if err != nil {
+		return nil, err
+	}
+	sen := &Sensor{
+		sim:   s,
+		attrs: a,
+		name:  name,
+		modes: append([]SensorMode(nil), modes...),
+	}
+	for _, attr := range []struct{ name, val string }{
+		{"address", address},
+		{"driver_name", driver},
+		{"modes", strings.Join(names, " ")},
+		{"commands", ""},
+		{"poll_ms", "10"},
+		{"uevent", uevent(address, driver)},
+	} {
+		err = sen.set(attr.name, attr.val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = sen.setMode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	s.nSensor++
+	s.sensors = append(s.sensors, sen)
+	return sen, nil
+}
+
+// Name returns the sysfs device name of the sensor.
+func (s *Sensor) Name() string { return s.name }
+
+// Mode returns the current mode of the sensor.
+func (s *Sensor) Mode() string {
+	s.sim.mu.Lock()
+	defer s.sim.mu.Unlock()
+	return s.modes[s.mode].Name
+}
+
+// SetValues sets the raw values of the sensor in its current mode. The
+// number of values must match the number of values in the current mode.
+func (s *Sensor) SetValues(values ...int) error {
+	s.sim.mu.Lock()
+	defer s.sim.mu.Unlock()
+	m := s.modes[s.mode]
+	if len(values) != m.NumValues {
+		return fmt.Errorf("sim: wrong number of values for mode %s: %d (want %d)", m.Name, len(values), m.NumValues)
+	}
+	return s.setValues(values)
+}
+
+// step applies a mode change made by the user. s.sim.mu must be held.
+func (s *Sensor) step() error {
+	val, ok, err := s.changed("mode")
+	if err != nil || !ok {
+		return err
+	}
+	for i, m := range s.modes {
+		if m.Name == val {
+			return s.setMode(i)
+		}
+	}
+	// Restore the current mode.
+	return s.setMode(s.mode)
+}
+
+// setMode sets the sensor mode, clearing its values.
+func (s *Sensor) setMode(i int) error {
+	s.mode = i
+	m := s.modes[i]
+	for _, attr := range []struct{ name, val string }{
+		{"mode", m.Name},
+		{"num_values", fmt.Sprint(m.NumValues)},
+		{"decimals", fmt.Sprint(m.Decimals)},
+		{"units", m.Units},
+		{"bin_data_format", m.BinDataFormat},
+	} {
+		err := s.set(attr.name, attr.val)
+		if err != nil {
+			return err
+		}
+	}
+	return s.setValues(make([]int, m.NumValues))
+}
+
+// setValues writes the value and bin_data attributes.
+func (s *Sensor) setValues(values []int) error {
+	for i := 0; i < maxValues; i++ {
+		v := 0
+		if i < len(values) {
+			v = values[i]
+		}
+		err := s.set(fmt.Sprint("value", i), fmt.Sprint(v))
+		if err != nil {
+			return err
+		}
+	}
+	format := s.modes[s.mode].BinDataFormat
+	size := ev3dev.BinDataSize(format)
+	b := make([]byte, size*len(values))
+	for i, v := range values {
+		p := b[i*size:]
+		switch format {
+		case "u8", "s8":
+			p[0] = byte(v)
+		case "u16", "s16":
+			binary.LittleEndian.PutUint16(p, uint16(v))
+		case "s16_be":
+			binary.BigEndian.PutUint16(p, uint16(v))
+		case "s32":
+			binary.LittleEndian.PutUint32(p, uint32(v))
+		case "s32_be":
+			binary.BigEndian.PutUint32(p, uint32(v))
+		case "float":
+			binary.LittleEndian.PutUint32(p, math.Float32bits(float32(v)))
+		}
+	}
+	return s.setBinary("bin_data", b)
+}
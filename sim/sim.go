@@ -0,0 +1,277 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Sim is a simulated set of ev3dev devices.
+type Sim struct {
+	root string
+
+	mu      sync.Mutex
+	motors  []*TachoMotor
+	sensors []*Sensor
+	leds    []*LED
+	nMotor  int
+	nSensor int
+	err     error
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a new Sim with its device tree rooted at the given directory.
+// The device class directories are created if they do not exist.
+func New(root string) (*Sim, error) {
+	for _, p := range []string{
+		ev3dev.LegoPortPath,
+		ev3dev.SensorPath,
+		ev3dev.TachoMotorPath,
+		ev3dev.LEDPath,
+		ev3dev.PowerSupplyPath,
+	} {
+		err := os.MkdirAll(filepath.Join(root, p), 0755)
+		if err != nil {
+			return nil, fmt.Errorf("sim: failed to create class directory: %v", err)
+		}
+	}
+	return &Sim{root: root}, nil
+}
+
+// Root returns the root of the Sim device tree.
+func (s *Sim) Root() string { return s.root }
+
+// Step advances the simulation by dt, processing any commands and
+// attribute changes made to the simulated devices since the last step.
+func (s *Sim) Step(dt time.Duration) error {
+	if dt < 0 {
+		return fmt.Errorf("sim: invalid time step: %v", dt)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.motors {
+		err := m.step(dt)
+		if err != nil {
+			return err
+		}
+	}
+	for _, sen := range s.sensors {
+		err := sen.step()
+		if err != nil {
+			return err
+		}
+	}
+	for _, l := range s.leds {
+		err := l.step()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start starts stepping the simulation in real time every period. Errors
+// during stepping stop the simulation and are returned by Err.
+func (s *Sim) Start(period time.Duration) error {
+	if period <= 0 {
+		return fmt.Errorf("sim: invalid period: %v", period)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return fmt.Errorf("sim: already started")
+	}
+	done := make(chan struct{})
+	s.done = done
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		tick := time.NewTicker(period)
+		defer tick.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-tick.C:
+				err := s.Step(now.Sub(last))
+				last = now
+				if err != nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Err returns the error that stopped a started simulation, if any.
+func (s *Sim) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops a started simulation. The device tree is left in place.
+func (s *Sim) Close() error {
+	s.mu.Lock()
+	done := s.done
+	s.done = nil
+	s.mu.Unlock()
+	if done != nil {
+		close(done)
+		s.wg.Wait()
+	}
+	return nil
+}
+
+// attrs is a set of attribute files for a simulated device. Attribute
+// files are rewritten in place so that open handles to them, such as
+// those held by ev3dev.Wait and ev3dev.CachedBackend, remain valid.
+type attrs struct {
+	dir string
+
+	// written holds the last value written
+	// by the simulation or read from a user
+	// write for each attribute.
+	written map[string]string
+}
+
+func newAttrs(dir string) (attrs, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return attrs{}, fmt.Errorf("sim: failed to create device directory: %v", err)
+	}
+	return attrs{dir: dir, written: make(map[string]string)}, nil
+}
+
+// set writes the attribute value.
+func (a *attrs) set(name, val string) error {
+	if a.written[name] == val {
+		if _, err := os.Stat(filepath.Join(a.dir, name)); err == nil {
+			return nil
+		}
+	}
+	err := a.write(name, []byte(val+"\n"))
+	if err != nil {
+		return err
+	}
+	a.written[name] = val
+	return nil
+}
+
+// publish writes the value of an attribute that may also be written by
+// users. If a user has written the attribute since it was last read by
+// changed, the user's value is left to be read on the next step.
+func (a *attrs) publish(name, val string) error {
+	cur, err := a.get(name)
+	if err != nil {
+		return err
+	}
+	if cur != a.written[name] {
+		return nil
+	}
+	return a.set(name, val)
+}
+
+// setBinary writes a binary attribute value.
+func (a *attrs) setBinary(name string, val []byte) error {
+	return a.write(name, val)
+}
+
+// write rewrites the contents of the attribute file in place, creating
+// it if necessary.
+func (a *attrs) write(name string, b []byte) error {
+	path := filepath.Join(a.dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("sim: failed to create attribute directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("sim: failed to write %s: %v", name, err)
+	}
+	err = f.Chmod(0666)
+	if err == nil {
+		_, err = f.WriteAt(b, 0)
+	}
+	if err == nil {
+		err = f.Truncate(int64(len(b)))
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("sim: failed to write %s: %v", name, err)
+	}
+	return nil
+}
+
+// get returns the current value of the attribute. The returned value is
+// empty if the file is empty or does not exist.
+func (a *attrs) get(name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(a.dir, name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("sim: failed to read %s: %v", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// changed returns the value of the attribute and whether it differs from
+// the value last written by the simulation. Empty values are not considered
+// changed since they may be observed during a user write.
+func (a *attrs) changed(name string) (string, bool, error) {
+	val, err := a.get(name)
+	if err != nil || val == "" || val == a.written[name] {
+		return val, false, err
+	}
+	a.written[name] = val
+	return val, true, nil
+}
+
+// take returns the value of the attribute and whether it has been written
+// by a user since the last call to take. The attribute is cleared when its
+// value is taken, so that writing the same value again is seen. It is used
+// for attributes such as command.
+func (a *attrs) take(name string) (string, bool, error) {
+	val, err := a.get(name)
+	if err != nil || val == "" {
+		return "", false, err
+	}
+	err = a.write(name, nil)
+	if err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+// remove removes the device directory.
+func (a *attrs) remove() error {
+	err := os.RemoveAll(a.dir)
+	if err != nil {
+		return fmt.Errorf("sim: failed to remove device: %v", err)
+	}
+	return nil
+}
+
+func uevent(address, driver string) string {
+	return fmt.Sprintf("LEGO_ADDRESS=%s\nLEGO_DRIVER_NAME=%s", address, driver)
+}
@@ -0,0 +1,395 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sim
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+func newTestSim(t *testing.T) (*Sim, func()) {
+	dir, err := ioutil.TempDir("", "ev3dev-sim")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	s, err := New(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("unexpected error creating sim: %v", err)
+	}
+	ev3dev.SetPrefix(dir)
+	return s, func() {
+		s.Close()
+		ev3dev.SetPrefix("")
+		os.RemoveAll(dir)
+	}
+}
+
+func step(t *testing.T, s *Sim, n int, dt time.Duration) {
+	for i := 0; i < n; i++ {
+		err := s.Step(dt)
+		if err != nil {
+			t.Fatalf("unexpected error stepping sim: %v", err)
+		}
+	}
+}
+
+func TestTachoMotor(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	sm, err := s.AddTachoMotor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error adding motor: %v", err)
+	}
+	m, err := ev3dev.TachoMotorFor("outA", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+	max, err := m.MaxSpeed()
+	if err != nil || max != 1050 {
+		t.Errorf("unexpected max speed: got:%d want:1050 (err=%v)", max, err)
+	}
+
+	err = m.SetSpeedSetpoint(500).SetRampUpSetpoint(time.Second).Command("run-forever").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 1, 100*time.Millisecond)
+	stat, err := m.State()
+	if err != nil || stat != ev3dev.Running|ev3dev.Ramping {
+		t.Errorf("unexpected state while ramping: got:%v want:%v (err=%v)", stat, ev3dev.Running|ev3dev.Ramping, err)
+	}
+	speed, err := m.Speed()
+	if err != nil || speed != 105 {
+		t.Errorf("unexpected speed while ramping: got:%d want:105 (err=%v)", speed, err)
+	}
+	step(t, s, 10, 100*time.Millisecond)
+	speed, err = m.Speed()
+	if err != nil || speed != 500 {
+		t.Errorf("unexpected speed after ramping: got:%d want:500 (err=%v)", speed, err)
+	}
+	stat, err = m.State()
+	if err != nil || stat != ev3dev.Running {
+		t.Errorf("unexpected state after ramping: got:%v want:%v (err=%v)", stat, ev3dev.Running, err)
+	}
+	pos, err := m.Position()
+	if err != nil || pos <= 0 {
+		t.Errorf("unexpected position while running: got:%d (err=%v)", pos, err)
+	}
+
+	err = m.SetPositionSetpoint(720).SetStopAction("hold").Command("run-to-abs-pos").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 100, 20*time.Millisecond)
+	pos, err = m.Position()
+	if err != nil || pos != 720 {
+		t.Errorf("unexpected position after run to position: got:%d want:720 (err=%v)", pos, err)
+	}
+	stat, err = m.State()
+	if err != nil || stat != ev3dev.Holding {
+		t.Errorf("unexpected state after run to position: got:%v want:%v (err=%v)", stat, ev3dev.Holding, err)
+	}
+
+	err = m.SetPositionSetpoint(-360).Command("run-to-rel-pos").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 100, 20*time.Millisecond)
+	if got := sm.Position(); got != 360 {
+		t.Errorf("unexpected position after run to relative position: got:%v want:360", got)
+	}
+
+	err = sm.SetLimits(0, 400)
+	if err != nil {
+		t.Fatalf("unexpected error setting limits: %v", err)
+	}
+	err = m.SetSpeedSetpoint(200).Command("run-forever").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 50, 20*time.Millisecond)
+	stat, err = m.State()
+	if err != nil || stat != ev3dev.Running|ev3dev.Stalled {
+		t.Errorf("unexpected state against limit: got:%v want:%v (err=%v)", stat, ev3dev.Running|ev3dev.Stalled, err)
+	}
+	pos, err = m.Position()
+	if err != nil || pos != 400 {
+		t.Errorf("unexpected position against limit: got:%d want:400 (err=%v)", pos, err)
+	}
+
+	err = m.Command("reset").Err()
+	if err != nil {
+		t.Fatalf("unexpected error resetting motor: %v", err)
+	}
+	step(t, s, 1, 20*time.Millisecond)
+	pos, err = m.Position()
+	if err != nil || pos != 0 {
+		t.Errorf("unexpected position after reset: got:%d want:0 (err=%v)", pos, err)
+	}
+	stat, err = m.State()
+	if err != nil || stat != 0 {
+		t.Errorf("unexpected state after reset: got:%v want:none (err=%v)", stat, err)
+	}
+}
+
+func TestTachoMotorAttributes(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	sm, err := s.AddTachoMotor("outC", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error adding motor: %v", err)
+	}
+	m, err := ev3dev.TachoMotorFor("outC", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+
+	// Repeated commands are seen however
+	// quickly they are written.
+	err = m.SetSpeedSetpoint(500).SetPositionSetpoint(90).Command("run-to-rel-pos").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 50, 20*time.Millisecond)
+	err = m.Command("run-to-rel-pos").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 50, 20*time.Millisecond)
+	pos, err := m.Position()
+	if err != nil || pos != 180 {
+		t.Errorf("unexpected position after repeated commands: got:%d want:180 (err=%v)", pos, err)
+	}
+
+	// A position written by the user is not
+	// overwritten before the sim reads it.
+	err = m.SetPosition(1000).Err()
+	if err != nil {
+		t.Fatalf("unexpected error setting position: %v", err)
+	}
+	err = sm.update()
+	if err != nil {
+		t.Fatalf("unexpected error updating motor: %v", err)
+	}
+	step(t, s, 1, 20*time.Millisecond)
+	if got := sm.Position(); got != 1000 {
+		t.Errorf("unexpected sim position after user write: got:%v want:1000", got)
+	}
+	pos, err = m.Position()
+	if err != nil || pos != 1000 {
+		t.Errorf("unexpected position after user write: got:%d want:1000 (err=%v)", pos, err)
+	}
+
+	// Attribute files are rewritten in place
+	// so cached handles remain valid.
+	cached := ev3dev.NewCachedBackend()
+	defer cached.Close()
+	path := filepath.Join(m.Path(), m.String(), "position")
+	got, err := cached.Read(path)
+	if err != nil || strings.TrimSpace(string(got)) != "1000" {
+		t.Errorf("unexpected cached position: got:%q want:%q (err=%v)", got, "1000", err)
+	}
+	err = m.Command("reset").Err()
+	if err != nil {
+		t.Fatalf("unexpected error resetting motor: %v", err)
+	}
+	step(t, s, 1, 20*time.Millisecond)
+	got, err = cached.Read(path)
+	if err != nil || strings.TrimSpace(string(got)) != "0" {
+		t.Errorf("unexpected cached position after reset: got:%q want:%q (err=%v)", got, "0", err)
+	}
+}
+
+func TestTachoMotorSetpointLatch(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	sm, err := s.AddTachoMotor("outD", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error adding motor: %v", err)
+	}
+	m, err := ev3dev.TachoMotorFor("outD", "lego-ev3-l-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+
+	err = m.SetSpeedSetpoint(500).Command("run-forever").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 10, 10*time.Millisecond)
+	if got := sm.Speed(); got != 500 {
+		t.Errorf("unexpected speed after run-forever: got:%v want:500", got)
+	}
+
+	// A changed setpoint has no effect until
+	// the command is issued again.
+	err = m.SetSpeedSetpoint(200).SetRampDownSetpoint(time.Second).Err()
+	if err != nil {
+		t.Fatalf("unexpected error setting speed: %v", err)
+	}
+	step(t, s, 10, 10*time.Millisecond)
+	if got := sm.Speed(); got != 500 {
+		t.Errorf("unexpected speed before command: got:%v want:500", got)
+	}
+	err = m.Command("run-forever").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	step(t, s, 1, 100*time.Millisecond)
+	if got := sm.Speed(); math.Abs(got-395) > 1e-9 {
+		t.Errorf("unexpected speed while ramping down: got:%v want:395", got)
+	}
+	step(t, s, 10, 100*time.Millisecond)
+	if got := sm.Speed(); got != 200 {
+		t.Errorf("unexpected speed after command: got:%v want:200", got)
+	}
+}
+
+func TestTachoMotorWait(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	_, err := s.AddTachoMotor("outB", "lego-ev3-m-motor")
+	if err != nil {
+		t.Fatalf("unexpected error adding motor: %v", err)
+	}
+	err = s.Start(5 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error starting sim: %v", err)
+	}
+
+	m, err := ev3dev.TachoMotorFor("outB", "lego-ev3-m-motor")
+	if err != nil {
+		t.Fatalf("unexpected error getting motor: %v", err)
+	}
+	err = m.SetSpeedSetpoint(1000).SetTimeSetpoint(100 * time.Millisecond).Command("run-timed").Err()
+	if err != nil {
+		t.Fatalf("unexpected error running motor: %v", err)
+	}
+	stat, ok, err := ev3dev.Wait(m, ev3dev.Running, 0, 0, false, 2*time.Second)
+	if err != nil || !ok {
+		t.Errorf("unexpected wait result: stat=%v ok=%t err=%v", stat, ok, err)
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("unexpected sim error: %v", err)
+	}
+}
+
+func TestSensor(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	ss, err := s.AddSensor("in2", "lego-ev3-gyro",
+		SensorMode{Name: "GYRO-ANG", NumValues: 1, Units: "deg", BinDataFormat: "s16"},
+		SensorMode{Name: "GYRO-G&A", NumValues: 2, BinDataFormat: "s16"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error adding sensor: %v", err)
+	}
+	sen, err := ev3dev.SensorFor("in2", "lego-ev3-gyro")
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor: %v", err)
+	}
+
+	err = ss.SetValues(-90)
+	if err != nil {
+		t.Fatalf("unexpected error setting values: %v", err)
+	}
+	vals, units, err := sen.ScaledValues()
+	if err != nil || !reflect.DeepEqual(vals, []float64{-90}) || units != "deg" {
+		t.Errorf("unexpected scaled values: got:%v %q want:[-90] \"deg\" (err=%v)", vals, units, err)
+	}
+	raw, err := sen.DecodedBinData()
+	if err != nil || !reflect.DeepEqual(raw, []int16{-90}) {
+		t.Errorf("unexpected binary data: got:%v want:[-90] (err=%v)", raw, err)
+	}
+
+	err = sen.SetMode("GYRO-G&A").Err()
+	if err != nil {
+		t.Fatalf("unexpected error setting mode: %v", err)
+	}
+	step(t, s, 1, 10*time.Millisecond)
+	if got := ss.Mode(); got != "GYRO-G&A" {
+		t.Errorf("unexpected sensor mode: got:%q want:\"GYRO-G&A\"", got)
+	}
+	err = ss.SetValues(45, -3)
+	if err != nil {
+		t.Fatalf("unexpected error setting values: %v", err)
+	}
+	vals, _, err = sen.ScaledValues()
+	if err != nil || !reflect.DeepEqual(vals, []float64{45, -3}) {
+		t.Errorf("unexpected scaled values: got:%v want:[45 -3] (err=%v)", vals, err)
+	}
+
+	_, err = s.AddSensor("in3", "fake", SensorMode{Name: "BAD", NumValues: 1, BinDataFormat: "s24"})
+	if err == nil {
+		t.Error("expected error for invalid bin data format")
+	}
+	fs, err := s.AddSensor("in4", "fake", SensorMode{Name: "FLOAT", NumValues: 2, BinDataFormat: "float"})
+	if err != nil {
+		t.Fatalf("unexpected error adding float sensor: %v", err)
+	}
+	err = fs.SetValues(3, -2)
+	if err != nil {
+		t.Fatalf("unexpected error setting values: %v", err)
+	}
+	fsen, err := ev3dev.SensorFor("in4", "fake")
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor: %v", err)
+	}
+	raw, err = fsen.DecodedBinData()
+	if err != nil || !reflect.DeepEqual(raw, []float32{3, -2}) {
+		t.Errorf("unexpected float binary data: got:%v want:[3 -2] (err=%v)", raw, err)
+	}
+}
+
+func TestLEDAndPowerSupply(t *testing.T) {
+	s, done := newTestSim(t)
+	defer done()
+
+	sl, err := s.AddLED("led0:green:brick-status")
+	if err != nil {
+		t.Fatalf("unexpected error adding LED: %v", err)
+	}
+	_, err = s.AddPowerSupply("lego-ev3-battery", 7.5)
+	if err != nil {
+		t.Fatalf("unexpected error adding power supply: %v", err)
+	}
+
+	l := &ev3dev.LED{Name: stringer("led0:green:brick-status")}
+	err = l.SetBrightness(128).SetTrigger("heartbeat").Err()
+	if err != nil {
+		t.Fatalf("unexpected error setting LED: %v", err)
+	}
+	step(t, s, 1, 10*time.Millisecond)
+	if got := sl.Brightness(); got != 128 {
+		t.Errorf("unexpected LED brightness: got:%d want:128", got)
+	}
+	trig, _, err := l.Trigger()
+	if err != nil || trig != "heartbeat" || sl.Trigger() != "heartbeat" {
+		t.Errorf("unexpected LED trigger: got:%q want:\"heartbeat\" (err=%v)", trig, err)
+	}
+
+	v, err := ev3dev.PowerSupply("").Voltage()
+	if err != nil || math.Abs(v-7.5) > 1e-9 {
+		t.Errorf("unexpected voltage: got:%v want:7.5 (err=%v)", v, err)
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
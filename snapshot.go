@@ -0,0 +1,193 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotFields is a set of motor attributes to read into a MotorSnapshot.
+type SnapshotFields uint
+
+const (
+	SnapshotPosition SnapshotFields = 1 << iota
+	SnapshotSpeed
+	SnapshotDutyCycle
+	SnapshotState
+)
+
+// snapshotAttrs are the attributes corresponding
+// to each SnapshotFields bit.
+var snapshotAttrs = [...]string{
+	position,
+	speed,
+	dutyCycle,
+	state,
+}
+
+// String satisfies the fmt.Stringer interface.
+func (f SnapshotFields) String() string {
+	var b []byte
+	for i, attr := range snapshotAttrs {
+		if f&(1<<uint(i)) != 0 {
+			if len(b) != 0 {
+				b = append(b, '|')
+			}
+			b = append(b, attr...)
+		}
+	}
+	if b == nil {
+		return "none"
+	}
+	return string(b)
+}
+
+// MotorSnapshot holds a set of motor attribute values read back-to-back.
+// Only the values of the attributes in Fields are valid.
+//
+// With the default SysfsBackend, all the attribute files are opened before
+// the first is read so that no open(2) calls separate the reads. Using a
+// CachedBackend avoids the opens entirely and is recommended for repeated
+// snapshots in control loops.
+type MotorSnapshot struct {
+	// Time is the time immediately
+	// before the first attribute
+	// was read.
+	Time time.Time
+
+	// Fields is the set of attributes
+	// held in the snapshot.
+	Fields SnapshotFields
+
+	Position  int
+	Speed     int
+	DutyCycle int
+	State     MotorState
+}
+
+// Snapshot returns the values of the requested attributes of the TachoMotor.
+// If fields is zero, all the attributes are read. The attributes are read
+// before any are parsed to minimise the time between reads.
+func (m *TachoMotor) Snapshot(fields SnapshotFields) (MotorSnapshot, error) {
+	return snapshotOf(m, fields, SnapshotPosition|SnapshotSpeed|SnapshotDutyCycle|SnapshotState)
+}
+
+// Snapshot returns the values of the requested attributes of the LinearActuator.
+// If fields is zero, all the attributes are read. The attributes are read
+// before any are parsed to minimise the time between reads.
+func (m *LinearActuator) Snapshot(fields SnapshotFields) (MotorSnapshot, error) {
+	return snapshotOf(m, fields, SnapshotPosition|SnapshotSpeed|SnapshotDutyCycle|SnapshotState)
+}
+
+// Snapshot returns the values of the requested attributes of the DCMotor.
+// Only SnapshotDutyCycle and SnapshotState are valid for a DCMotor. If
+// fields is zero, both attributes are read.
+func (m *DCMotor) Snapshot(fields SnapshotFields) (MotorSnapshot, error) {
+	return snapshotOf(m, fields, SnapshotDutyCycle|SnapshotState)
+}
+
+// snapshotOf returns a snapshot of the requested fields of d. The fields
+// must be a subset of valid. If fields is zero, valid is used.
+func snapshotOf(d Device, fields, valid SnapshotFields) (MotorSnapshot, error) {
+	err := d.Err()
+	if err != nil {
+		return MotorSnapshot{}, err
+	}
+	if fields == 0 {
+		fields = valid
+	}
+	if fields&^valid != 0 {
+		return MotorSnapshot{}, fmt.Errorf("ev3dev: invalid snapshot fields %#x for %T (valid fields: %v)", uint(fields), d, valid)
+	}
+
+	dir := filepath.Join(d.Path(), d.String())
+	var (
+		raw  [len(snapshotAttrs)][]byte
+		snap = MotorSnapshot{Fields: fields}
+	)
+	switch backend.(type) {
+	case SysfsBackend, *SysfsBackend:
+		snap.Time, err = preadSnapshot(d, dir, fields, &raw)
+		if err != nil {
+			return MotorSnapshot{}, err
+		}
+	default:
+		// CachedBackend reads with pread(2) on its open
+		// attribute files, so it needs no special handling.
+		snap.Time = time.Now()
+		for i, attr := range snapshotAttrs {
+			if fields&(1<<uint(i)) == 0 {
+				continue
+			}
+			raw[i], err = backend.Read(filepath.Join(dir, attr))
+			if err != nil {
+				return MotorSnapshot{}, newAttrOpError(d, attr, "", "read", err)
+			}
+		}
+	}
+
+	for i, attr := range snapshotAttrs {
+		if fields&(1<<uint(i)) == 0 {
+			continue
+		}
+		data := string(chomp(raw[i]))
+		switch attr {
+		case position:
+			snap.Position, err = intFrom(d, data, attr, nil)
+		case speed:
+			snap.Speed, err = intFrom(d, data, attr, nil)
+		case dutyCycle:
+			snap.DutyCycle, err = intFrom(d, data, attr, nil)
+		case state:
+			snap.State, err = stateFrom(d, data, attr, nil)
+		}
+		if err != nil {
+			return MotorSnapshot{}, err
+		}
+	}
+	return snap, nil
+}
+
+// preadSnapshot reads the requested fields of the device in dir into raw.
+// All the attribute files are opened before any is read so that the reads
+// are made back-to-back with pread(2). The returned time is the time
+// immediately before the first read.
+func preadSnapshot(d Device, dir string, fields SnapshotFields, raw *[len(snapshotAttrs)][]byte) (time.Time, error) {
+	var files [len(snapshotAttrs)]*os.File
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i, attr := range snapshotAttrs {
+		if fields&(1<<uint(i)) == 0 {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, attr))
+		if err != nil {
+			return time.Time{}, newAttrOpError(d, attr, "", "read", err)
+		}
+		files[i] = f
+	}
+
+	buf := make([]byte, attrBufLen)
+	now := time.Now()
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		n, err := pread(int(f.Fd()), buf, 0)
+		if err != nil {
+			return time.Time{}, newAttrOpError(d, snapshotAttrs[i], "", "read", &os.PathError{Op: "read", Path: f.Name(), Err: err})
+		}
+		raw[i] = append([]byte(nil), buf[:n]...)
+	}
+	return now, nil
+}
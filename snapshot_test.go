@@ -0,0 +1,167 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ev3dev
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	mem := NewMemoryBackend()
+	prev := SetBackend(mem)
+	defer SetBackend(prev)
+
+	for path, attrs := range map[string]map[string]string{
+		filepath.Join(prefix, TachoMotorPath, "motor0"): {
+			position:  "-720",
+			speed:     "350",
+			dutyCycle: "42",
+			state:     "running ramping",
+		},
+		filepath.Join(prefix, DCMotorPath, "motor1"): {
+			dutyCycle: "-60",
+			state:     "running",
+		},
+	} {
+		for attr, val := range attrs {
+			err := mem.Write(filepath.Join(path, attr), []byte(val+"\n"))
+			if err != nil {
+				t.Fatalf("unexpected error writing %s: %v", attr, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		dev interface {
+			Snapshot(SnapshotFields) (MotorSnapshot, error)
+		}
+		fields  SnapshotFields
+		want    MotorSnapshot
+		wantErr bool
+	}{
+		{
+			dev:    &TachoMotor{id: 0},
+			fields: 0,
+			want: MotorSnapshot{
+				Fields:    SnapshotPosition | SnapshotSpeed | SnapshotDutyCycle | SnapshotState,
+				Position:  -720,
+				Speed:     350,
+				DutyCycle: 42,
+				State:     Running | Ramping,
+			},
+		},
+		{
+			dev:    &TachoMotor{id: 0},
+			fields: SnapshotPosition | SnapshotState,
+			want: MotorSnapshot{
+				Fields:   SnapshotPosition | SnapshotState,
+				Position: -720,
+				State:    Running | Ramping,
+			},
+		},
+		{
+			dev:    &DCMotor{id: 1},
+			fields: 0,
+			want: MotorSnapshot{
+				Fields:    SnapshotDutyCycle | SnapshotState,
+				DutyCycle: -60,
+				State:     Running,
+			},
+		},
+		{
+			dev:     &DCMotor{id: 1},
+			fields:  SnapshotPosition,
+			wantErr: true,
+		},
+		{
+			dev:     &TachoMotor{id: 2},
+			fields:  SnapshotSpeed,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		before := time.Now()
+		got, err := test.dev.Snapshot(test.fields)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("expected error for %T snapshot of %v", test.dev, test.fields)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %T snapshot of %v: %v", test.dev, test.fields, err)
+			continue
+		}
+		if got.Time.Before(before) || got.Time.After(time.Now()) {
+			t.Errorf("unexpected snapshot time: got:%v", got.Time)
+		}
+		got.Time = time.Time{}
+		if got != test.want {
+			t.Errorf("unexpected %T snapshot of %v:\ngot: %+v\nwant:%+v", test.dev, test.fields, got, test.want)
+		}
+	}
+}
+
+func TestSnapshotSysfs(t *testing.T) {
+	root, err := ioutil.TempDir("", "ev3dev-snapshot")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, TachoMotorPath, "motor0")
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatalf("failed to create device directory: %v", err)
+	}
+	for attr, val := range map[string]string{
+		position:  "-720",
+		speed:     "350",
+		dutyCycle: "42",
+		state:     "running ramping",
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, attr), []byte(val+"\n"), 0644)
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", attr, err)
+		}
+	}
+
+	prevPrefix := prefix
+	prefix = root
+	defer func() { prefix = prevPrefix }()
+	for _, b := range []Backend{SysfsBackend{}, NewCachedBackend()} {
+		prev := SetBackend(b)
+
+		want := MotorSnapshot{
+			Fields:    SnapshotPosition | SnapshotSpeed | SnapshotDutyCycle | SnapshotState,
+			Position:  -720,
+			Speed:     350,
+			DutyCycle: 42,
+			State:     Running | Ramping,
+		}
+		got, err := (&TachoMotor{id: 0}).Snapshot(0)
+		if err != nil {
+			t.Errorf("unexpected error for %T snapshot: %v", b, err)
+		}
+		got.Time = time.Time{}
+		if got != want {
+			t.Errorf("unexpected %T snapshot:\ngot: %+v\nwant:%+v", b, got, want)
+		}
+
+		_, err = (&TachoMotor{id: 1}).Snapshot(SnapshotSpeed)
+		if err == nil {
+			t.Errorf("expected error for %T snapshot of missing motor", b)
+		}
+
+		SetBackend(prev)
+		if c, ok := b.(*CachedBackend); ok {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package telemetry provides recording of ev3dev device attributes and
+// replay of recorded sensor data.
+//
+// A Recorder samples device attributes at fixed rates and writes the
+// timestamped samples as CSV or JSON lines. A Replay reads recorded samples
+// into an ev3dev.MemoryBackend so that the recorded values can be read back
+// through the ev3dev API:
+//
+//	r, err := telemetry.NewReplay(f, telemetry.CSV)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ev3dev.SetBackend(r.Backend())
+//
+//	s, err := ev3dev.SensorFor("in1", "lego-ev3-gyro")
+//	...
+//	for !r.Done() {
+//		r.Step(10 * time.Millisecond)
+//		vals, _, err := s.ScaledValues()
+//		...
+//	}
+package telemetry
@@ -0,0 +1,184 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Device is a device with a sysfs attribute directory. All ev3dev device
+// handles, including ev3dev.PowerSupply, satisfy Device.
+type Device interface {
+	// Path returns the sysfs class path
+	// of the device.
+	Path() string
+
+	// String returns the name of the
+	// device in its class directory.
+	String() string
+}
+
+// Channel is a device attribute to record.
+type Channel struct {
+	// Name is the name of the channel
+	// in the recorded samples.
+	Name string
+
+	// Device and Attr specify the
+	// attribute to record.
+	Device Device
+	Attr   string
+
+	// Period is the sampling period of
+	// the channel. If Period is zero,
+	// the attribute is sampled once
+	// when recording starts.
+	Period time.Duration
+}
+
+// path returns the path of the channel's attribute.
+func (c Channel) path() string {
+	return filepath.Join(c.Device.Path(), c.Device.String(), c.Attr)
+}
+
+// Attr returns a channel for the attribute attr of d named by the device
+// name and attribute, sampled every period.
+func Attr(d Device, attr string, period time.Duration) Channel {
+	return Channel{Name: d.String() + "." + attr, Device: d, Attr: attr, Period: period}
+}
+
+// SensorChannels returns channels for the values of the sensor in its
+// current mode sampled every period, and the channels describing the sensor
+// that are needed to replay the values through the ev3dev.Sensor API.
+// The descriptive channels are sampled once when recording starts.
+func SensorChannels(s *ev3dev.Sensor, period time.Duration) ([]Channel, error) {
+	n, err := s.NumValues()
+	if err != nil {
+		return nil, err
+	}
+	var channels []Channel
+	for _, attr := range []string{"address", "driver_name", "modes", "mode", "num_values", "decimals", "units", "bin_data_format"} {
+		channels = append(channels, Attr(s, attr, 0))
+	}
+	for i := 0; i < n; i++ {
+		channels = append(channels, Attr(s, fmt.Sprint("value", i), period))
+	}
+	return channels, nil
+}
+
+// Recorder samples device attributes and writes them to an io.Writer.
+type Recorder struct {
+	mu  sync.Mutex
+	w   *sampleWriter
+	err error
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewRecorder returns a running Recorder writing samples of the given
+// channels to w in the specified format. Each channel is sampled in its
+// own goroutine. Attribute read errors are recorded in the samples, while
+// errors writing to w stop the Recorder and are returned by Err and Close.
+func NewRecorder(w io.Writer, f Format, channels ...Channel) (*Recorder, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("telemetry: no channels to record")
+	}
+	for _, c := range channels {
+		if c.Device == nil {
+			return nil, fmt.Errorf("telemetry: nil device for channel %q", c.Name)
+		}
+		if c.Period < 0 {
+			return nil, fmt.Errorf("telemetry: invalid sample period for channel %q: %v", c.Name, c.Period)
+		}
+	}
+	sw, err := newSampleWriter(w, f)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{w: sw, done: make(chan struct{})}
+	for _, c := range channels {
+		r.wg.Add(1)
+		go r.record(c)
+	}
+	return r, nil
+}
+
+// record samples the channel c until the Recorder is closed.
+func (r *Recorder) record(c Channel) {
+	defer r.wg.Done()
+
+	path := c.path()
+	if !r.sample(c.Name, path) || c.Period == 0 {
+		return
+	}
+	tick := time.NewTicker(c.Period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-tick.C:
+			if !r.sample(c.Name, path) {
+				return
+			}
+		}
+	}
+}
+
+// sample reads the attribute at path and writes the sample. It returns
+// false if the sample could not be written.
+func (r *Recorder) sample(channel, path string) bool {
+	b, err := ev3dev.CurrentBackend().Read(path)
+	s := Sample{
+		Time:    time.Now(),
+		Channel: channel,
+		Path:    path,
+		Value:   string(bytes.TrimSuffix(b, []byte("\n"))),
+	}
+	if err != nil {
+		s.Err = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return false
+	}
+	r.err = r.w.write(s)
+	return r.err == nil
+}
+
+// Err returns the first error encountered writing samples.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stops the Recorder and flushes any buffered samples. It returns
+// the first error encountered writing samples. Close is safe to call more
+// than once and concurrently.
+func (r *Recorder) Close() error {
+	r.once.Do(func() { close(r.done) })
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.w.flush()
+	}
+	return r.err
+}
@@ -0,0 +1,118 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+// Replay replays recorded samples into an ev3dev.MemoryBackend. Samples are
+// applied in time order as the replay clock is advanced, so the recorded
+// attribute values can be read through the ev3dev API by setting the Replay
+// backend as the ev3dev backend. Samples recorded with errors are skipped.
+type Replay struct {
+	mem     *ev3dev.MemoryBackend
+	samples []Sample
+	start   time.Time
+	elapsed time.Duration
+	next    int
+}
+
+// NewReplay returns a Replay of the samples read from r in the given
+// format. The samples at the start of the recording are applied before
+// NewReplay returns.
+func NewReplay(r io.Reader, f Format) (*Replay, error) {
+	samples, err := ReadSamples(r, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("telemetry: no samples to replay")
+	}
+	sort.Stable(byTime(samples))
+	p := &Replay{
+		mem:     ev3dev.NewMemoryBackend(),
+		samples: samples,
+		start:   samples[0].Time,
+	}
+	return p, p.Step(0)
+}
+
+// byTime sorts samples by time.
+type byTime []Sample
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Backend returns the backend holding the replayed attribute values.
+func (p *Replay) Backend() *ev3dev.MemoryBackend { return p.mem }
+
+// Elapsed returns the current time of the replay clock relative to the
+// first sample.
+func (p *Replay) Elapsed() time.Duration { return p.elapsed }
+
+// Duration returns the time between the first and last samples.
+func (p *Replay) Duration() time.Duration {
+	return p.samples[len(p.samples)-1].Time.Sub(p.start)
+}
+
+// Done returns whether all the samples have been applied.
+func (p *Replay) Done() bool { return p.next == len(p.samples) }
+
+// Step advances the replay clock by dt and applies all samples recorded
+// up to the new time.
+func (p *Replay) Step(dt time.Duration) error {
+	if dt < 0 {
+		return errors.New("telemetry: negative replay step")
+	}
+	p.elapsed += dt
+	now := p.start.Add(p.elapsed)
+	for ; p.next < len(p.samples); p.next++ {
+		s := p.samples[p.next]
+		if s.Time.After(now) {
+			break
+		}
+		if s.Err != "" {
+			continue
+		}
+		err := p.mem.Write(s.Path, []byte(s.Value+"\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run replays the remaining samples in real time, stepping the replay clock
+// every period until all the samples have been applied or ctx is done.
+// Step must not be called while Run is running.
+func (p *Replay) Run(ctx context.Context, period time.Duration) error {
+	if period <= 0 {
+		return errors.New("telemetry: invalid replay period")
+	}
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	last := time.Now()
+	for !p.Done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			err := p.Step(now.Sub(last))
+			if err != nil {
+				return err
+			}
+			last = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format is a telemetry file format.
+type Format int
+
+const (
+	// CSV is comma-separated values with
+	// a header line. The columns are time,
+	// channel, path, value and error.
+	CSV Format = iota
+
+	// JSONLines is one JSON object per
+	// line. Each object is a Sample.
+	JSONLines
+)
+
+// String satisfies the fmt.Stringer interface.
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case JSONLines:
+		return "jsonl"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// csvHeader is the header line of CSV telemetry files.
+var csvHeader = []string{"time", "channel", "path", "value", "error"}
+
+// Sample is a single sampled attribute value.
+type Sample struct {
+	// Time is the time the
+	// attribute was read.
+	Time time.Time `json:"time"`
+
+	// Channel is the name of
+	// the recorded channel.
+	Channel string `json:"channel"`
+
+	// Path is the path of the
+	// sampled attribute.
+	Path string `json:"path"`
+
+	// Value is the attribute value
+	// without a trailing newline.
+	Value string `json:"value"`
+
+	// Err is the error reading
+	// the attribute, if any.
+	Err string `json:"error,omitempty"`
+}
+
+// sampleWriter writes samples in a Format.
+type sampleWriter struct {
+	format Format
+	w      *bufio.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+func newSampleWriter(w io.Writer, f Format) (*sampleWriter, error) {
+	sw := &sampleWriter{format: f, w: bufio.NewWriter(w)}
+	switch f {
+	case CSV:
+		sw.csv = csv.NewWriter(sw.w)
+		err := sw.csv.Write(csvHeader)
+		if err != nil {
+			return nil, err
+		}
+	case JSONLines:
+		sw.json = json.NewEncoder(sw.w)
+	default:
+		return nil, fmt.Errorf("telemetry: unknown format: %v", f)
+	}
+	return sw, nil
+}
+
+func (w *sampleWriter) write(s Sample) error {
+	if w.format == CSV {
+		return w.csv.Write([]string{s.Time.Format(time.RFC3339Nano), s.Channel, s.Path, s.Value, s.Err})
+	}
+	return w.json.Encode(s)
+}
+
+func (w *sampleWriter) flush() error {
+	if w.format == CSV {
+		w.csv.Flush()
+		err := w.csv.Error()
+		if err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// ReadSamples reads all the samples from r in the given format.
+func ReadSamples(r io.Reader, f Format) ([]Sample, error) {
+	var samples []Sample
+	switch f {
+	case CSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = len(csvHeader)
+		head, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: failed to read CSV header: %v", err)
+		}
+		for i, h := range csvHeader {
+			if head[i] != h {
+				return nil, fmt.Errorf("telemetry: invalid CSV header: %q", head)
+			}
+		}
+		for {
+			rec, err := cr.Read()
+			if err == io.EOF {
+				return samples, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("telemetry: failed to read CSV record: %v", err)
+			}
+			t, err := time.Parse(time.RFC3339Nano, rec[0])
+			if err != nil {
+				return nil, fmt.Errorf("telemetry: invalid sample time: %v", err)
+			}
+			samples = append(samples, Sample{Time: t, Channel: rec[1], Path: rec[2], Value: rec[3], Err: rec[4]})
+		}
+	case JSONLines:
+		dec := json.NewDecoder(r)
+		for {
+			var s Sample
+			err := dec.Decode(&s)
+			if err == io.EOF {
+				return samples, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("telemetry: failed to read JSON sample: %v", err)
+			}
+			samples = append(samples, s)
+		}
+	default:
+		return nil, fmt.Errorf("telemetry: unknown format: %v", f)
+	}
+}
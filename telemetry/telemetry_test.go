@@ -0,0 +1,197 @@
+// Copyright ©2016 The ev3go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ev3go/ev3dev"
+)
+
+var sensorAttrs = map[string]string{
+	"address":         "in1",
+	"driver_name":     "lego-ev3-gyro",
+	"modes":           "GYRO-ANG GYRO-RATE",
+	"mode":            "GYRO-ANG",
+	"num_values":      "1",
+	"decimals":        "0",
+	"units":           "deg",
+	"bin_data_format": "s16",
+	"value0":          "0",
+}
+
+func TestRecorder(t *testing.T) {
+	mem := ev3dev.NewMemoryBackend()
+	dir := filepath.Join(ev3dev.SensorPath, "sensor0")
+	for attr, val := range sensorAttrs {
+		err := mem.Write(filepath.Join(dir, attr), []byte(val+"\n"))
+		if err != nil {
+			t.Fatalf("unexpected error writing %s: %v", attr, err)
+		}
+	}
+	err := mem.Write(filepath.Join(ev3dev.PowerSupplyPath, "lego-ev3-battery", "voltage_now"), []byte("7500000\n"))
+	if err != nil {
+		t.Fatalf("unexpected error writing voltage: %v", err)
+	}
+	prev := ev3dev.SetBackend(mem)
+	defer ev3dev.SetBackend(prev)
+
+	s, err := ev3dev.SensorFor("in1", "lego-ev3-gyro")
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor: %v", err)
+	}
+	channels, err := SensorChannels(s, time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error getting sensor channels: %v", err)
+	}
+	channels = append(channels,
+		Attr(ev3dev.PowerSupply("lego-ev3-battery"), "voltage_now", time.Millisecond),
+		Attr(s, "missing", 0),
+	)
+
+	for _, f := range []Format{CSV, JSONLines} {
+		var buf bytes.Buffer
+		r, err := NewRecorder(&buf, f, channels...)
+		if err != nil {
+			t.Fatalf("unexpected error creating %v recorder: %v", f, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		// Concurrent calls to Close must not panic.
+		closed := make(chan error)
+		for i := 0; i < 2; i++ {
+			go func() { closed <- r.Close() }()
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case err = <-closed:
+				if err != nil {
+					t.Fatalf("unexpected error closing %v recorder: %v", f, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for %v recorder close", f)
+			}
+		}
+
+		samples, err := ReadSamples(&buf, f)
+		if err != nil {
+			t.Fatalf("unexpected error reading %v samples: %v", f, err)
+		}
+		counts := make(map[string]int)
+		for _, s := range samples {
+			counts[s.Channel]++
+			switch s.Channel {
+			case "sensor0.value0":
+				if s.Value != "0" || s.Err != "" {
+					t.Errorf("unexpected %v value sample: %+v", f, s)
+				}
+			case "lego-ev3-battery.voltage_now":
+				if s.Value != "7500000" || s.Err != "" {
+					t.Errorf("unexpected %v voltage sample: %+v", f, s)
+				}
+			case "sensor0.missing":
+				if s.Err == "" {
+					t.Errorf("expected error for %v missing attribute sample: %+v", f, s)
+				}
+			}
+		}
+		for attr := range sensorAttrs {
+			name := "sensor0." + attr
+			if attr == "value0" {
+				if counts[name] < 2 {
+					t.Errorf("too few %v samples for %s: got:%d", f, name, counts[name])
+				}
+				continue
+			}
+			if counts[name] != 1 {
+				t.Errorf("unexpected number of %v samples for %s: got:%d want:1", f, name, counts[name])
+			}
+		}
+		if counts["lego-ev3-battery.voltage_now"] < 2 {
+			t.Errorf("too few %v voltage samples: got:%d", f, counts["lego-ev3-battery.voltage_now"])
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+	dir := filepath.Join(ev3dev.SensorPath, "sensor3")
+	var samples []Sample
+	for attr, val := range sensorAttrs {
+		samples = append(samples, Sample{Time: start, Channel: "sensor3." + attr, Path: filepath.Join(dir, attr), Value: val})
+	}
+	for i, v := range []string{"10", "20", "30"} {
+		samples = append(samples, Sample{
+			Time:    start.Add(time.Duration(i+1) * 10 * time.Millisecond),
+			Channel: "sensor3.value0",
+			Path:    filepath.Join(dir, "value0"),
+			Value:   v,
+		})
+	}
+	samples = append(samples, Sample{
+		Time:    start.Add(35 * time.Millisecond),
+		Channel: "sensor3.value0",
+		Path:    filepath.Join(dir, "value0"),
+		Err:     "read error",
+	})
+
+	for _, f := range []Format{CSV, JSONLines} {
+		var buf bytes.Buffer
+		w, err := newSampleWriter(&buf, f)
+		if err != nil {
+			t.Fatalf("unexpected error creating %v writer: %v", f, err)
+		}
+		// Write samples in reverse order to check sorting.
+		for i := len(samples) - 1; i >= 0; i-- {
+			err = w.write(samples[i])
+			if err != nil {
+				t.Fatalf("unexpected error writing %v sample: %v", f, err)
+			}
+		}
+		err = w.flush()
+		if err != nil {
+			t.Fatalf("unexpected error flushing %v samples: %v", f, err)
+		}
+
+		p, err := NewReplay(&buf, f)
+		if err != nil {
+			t.Fatalf("unexpected error creating %v replay: %v", f, err)
+		}
+		if p.Duration() != 35*time.Millisecond {
+			t.Errorf("unexpected %v replay duration: got:%v want:35ms", f, p.Duration())
+		}
+		prev := ev3dev.SetBackend(p.Backend())
+
+		s, err := ev3dev.SensorFor("in1", "lego-ev3-gyro")
+		if err != nil {
+			ev3dev.SetBackend(prev)
+			t.Fatalf("unexpected error getting %v replay sensor: %v", f, err)
+		}
+		var got []float64
+		for !p.Done() {
+			vals, units, err := s.ScaledValues()
+			if err != nil || units != "deg" {
+				t.Errorf("unexpected %v replay values: units=%q err=%v", f, units, err)
+				break
+			}
+			got = append(got, vals...)
+			err = p.Step(10 * time.Millisecond)
+			if err != nil {
+				t.Errorf("unexpected error stepping %v replay: %v", f, err)
+				break
+			}
+		}
+		ev3dev.SetBackend(prev)
+
+		want := []float64{0, 10, 20, 30}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected %v replayed values: got:%v want:%v", f, got, want)
+		}
+	}
+}
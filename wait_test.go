@@ -5,6 +5,7 @@
 package ev3dev_test
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -376,4 +377,57 @@ func TestWaitMotor(t *testing.T) {
 			wg.Wait()
 		}
 	})
+
+	t.Run("StateContext", func(t *testing.T) {
+		m, err := TachoMotorFor(conn.waitMotor.address, conn.waitMotor.driver)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		conn.waitMotor.setState(Running)
+		err = fs.InvalidatePath(filepath.Join(m.Path(), m.String(), StateName))
+		if err != nil {
+			t.Fatalf("unexpected error invalidating state: %v", err)
+		}
+
+		const cancelAfter = 200 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(cancelAfter, cancel)
+
+		start := time.Now()
+		_, ok, err := WaitContext(ctx, m, Running, 0, 0, false)
+		elapsed := time.Since(start)
+		if ok {
+			t.Error("unexpected success waiting for unreached state")
+		}
+		if err != context.Canceled {
+			t.Errorf("unexpected error: got:%v want:%v", err, context.Canceled)
+		}
+		if elapsed > 5*cancelAfter {
+			t.Errorf("cancellation not prompt: took %v", elapsed)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), cancelAfter)
+		defer cancel()
+		_, ok, err = WaitContext(ctx, m, Running, 0, 0, false)
+		if ok {
+			t.Error("unexpected success waiting for unreached state")
+		}
+		if err != context.DeadlineExceeded {
+			t.Errorf("unexpected error: got:%v want:%v", err, context.DeadlineExceeded)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		got, ok, err := WaitContext(ctx, m, Running, Running, 0, false)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !ok {
+			t.Error("expected success waiting for current state")
+		}
+		if got != Running {
+			t.Errorf("unexpected motor state: got:%v want:%v", got, Running)
+		}
+	})
 }